@@ -0,0 +1,193 @@
+package dmlog
+
+import "errors"
+import "fmt"
+import "sync/atomic"
+import "time"
+
+// How often the number of dropped messages is reported by a synthetic warning message.
+const dropReportInterval = 10*time.Second
+
+/* Wrapped by the error returned by Flush() when messages were dropped since the previous flush, see
+   Backpressure. */
+var ErrDroppedMessages = errors.New("log messages were dropped")
+
+// What happens to a message when the channel of the logger is full.
+type BackpressurePolicy int8
+
+// The supported backpressure policies.
+const (
+    BlockBackpressure BackpressurePolicy = iota // The caller waits until there is room.
+    BlockTimeoutBackpressure // The caller waits at most Timeout, then the message is dropped.
+    DropNewestBackpressure // The message is dropped.
+    DropOldestBackpressure // The oldest message in the channel is dropped to make room.
+    DropBelowSeverityBackpressure // Messages below MinSeverity are dropped, the others wait.
+)
+
+/* The behaviour of the logger when its channel is full, e.g. during a burst of messages.
+   The zero value blocks the caller.  The policy does not apply to the queues of the sinks: when the
+   queue of a slow sink is full, the message is dropped for that sink only, see SinkStats.
+   The dropped messages, by the policy or by a sink, are counted, see DroppedMessages(), and their number is reported 
+   periodically, and by Flush(), by a warning message.  Flush() also returns an error wrapping 
+   ErrDroppedMessages when messages were dropped since the previous flush. */
+type Backpressure struct {
+    Policy BackpressurePolicy
+
+    // The longest wait of the BlockTimeoutBackpressure policy.
+    Timeout time.Duration
+
+    // The lowest severity not dropped by the DropBelowSeverityBackpressure policy.
+    MinSeverity LogSeverity
+}
+
+// Implements the Stringable interface
+func (b BackpressurePolicy) String() string {
+    switch b {
+        case BlockBackpressure: return "block"
+        case BlockTimeoutBackpressure: return "block with timeout"
+        case DropNewestBackpressure: return "drop newest"
+        case DropOldestBackpressure: return "drop oldest"
+        case DropBelowSeverityBackpressure: return "drop below severity"
+    }
+    return "Unknown"
+}
+
+// Sets the backpressure policy of the default logger.
+func SetBackpressure( backpressure Backpressure) error {
+    return DefaultLogger().SetBackpressure( backpressure)
+}
+
+/* Retrieves the number of messages dropped by the default logger because its channel, or the queue
+   of a sink, was full. */
+func DroppedMessages() uint64 {
+    return DefaultLogger().DroppedMessages()
+}
+
+// Sets the backpressure policy of the logger, see SetBackpressure().
+func (l *Logger) SetBackpressure( backpressure Backpressure) error {
+    switch backpressure.Policy {
+        case BlockBackpressure, DropNewestBackpressure, DropOldestBackpressure:
+        case BlockTimeoutBackpressure:
+            if backpressure.Timeout<=0 {
+                return fmt.Errorf("invalid timeout %s", backpressure.Timeout)
+            }
+        case DropBelowSeverityBackpressure:
+            if backpressure.MinSeverity<DebugSeverity || backpressure.MinSeverity>FatalSeverity {
+                return fmt.Errorf("invalid severity %d", backpressure.MinSeverity)
+            }
+        default:
+            return fmt.Errorf("invalid backpressure policy %d", backpressure.Policy)
+    }
+    l.mtxBackpressure.Lock()
+    defer l.mtxBackpressure.Unlock()
+    l.backpressure= backpressure
+    return nil
+}
+
+/* Retrieves the number of messages dropped by the logger because its channel, or the queue of a
+   sink, was full.  A message dropped by several sinks is counted once. */
+func (l *Logger) DroppedMessages() uint64 {
+    return atomic.LoadUint64( &l.droppedMessages)
+}
+
+//--------------------------------------------------------------------------------------------------
+/* Sends the message to the dispatcher, according to the backpressure policy.  
+   Returns false if the message was dropped. */
+func (l *Logger) sendLogMessage( message LogMessage) bool {
+    select {
+        case l.chLogMessages <- message:
+            return true
+        default:
+    }
+
+    backpressure := l.getBackpressure()
+    switch backpressure.Policy {
+        case BlockTimeoutBackpressure: {
+            timer := time.NewTimer( backpressure.Timeout)
+            defer timer.Stop()
+            select {
+                case l.chLogMessages <- message:
+                    return true
+                case <- timer.C:
+            }
+        }
+        case DropNewestBackpressure:
+        case DropOldestBackpressure: {
+            // Other senders compete for the room: the loop ends as soon as the message is sent.
+            for {
+                select {
+                    case l.chLogMessages <- message:
+                        return true
+                    default:
+                }
+                select {
+                    case <- l.chLogMessages:
+                        atomic.AddUint64( &l.droppedMessages, 1)
+                    default:
+                }
+            }
+        }
+        case DropBelowSeverityBackpressure: {
+            if message.severity.IsGreaterOrEqualThan( backpressure.MinSeverity) {
+                l.chLogMessages <- message
+                return true
+            }
+        }
+        default: {
+            l.chLogMessages <- message
+            return true
+        }
+    }
+    atomic.AddUint64( &l.droppedMessages, 1)
+    return false
+}
+
+//--------------------------------------------------------------------------------------------------
+func (l *Logger) getBackpressure() Backpressure {
+    l.mtxBackpressure.RLock()
+    defer l.mtxBackpressure.RUnlock()
+    return l.backpressure
+}
+
+//--------------------------------------------------------------------------------------------------
+/* Retrieves the error returned by Flush() for the messages dropped since the previous flush; nil if
+   none was dropped.  It is called once the sinks are flushed, as they can drop the messages they 
+   are still writing; concurrent flushes report each drop once. */
+func (l *Logger) flushDroppedMessages() error {
+    dropped := atomic.LoadUint64( &l.droppedMessages)
+    for {
+        flushed := atomic.LoadUint64( &l.flushedDroppedMessages)
+        if dropped<=flushed {
+            return nil
+        }
+        if atomic.CompareAndSwapUint64( &l.flushedDroppedMessages, flushed, dropped) {
+            return fmt.Errorf("%d %w since the previous flush", dropped- flushed, ErrDroppedMessages)
+        }
+    }
+}
+
+//--------------------------------------------------------------------------------------------------
+/* Delivers to the sinks a warning message with the number of messages dropped since the last
+   report, if any. */
+func (l *Logger) reportDroppedMessages( ctx *ctxMessageDispatcher, isBlocking bool) {
+    dropped := atomic.LoadUint64( &l.droppedMessages)
+    if dropped==ctx.reportedDroppedMessages {
+        return
+    }
+    numDropped := dropped- ctx.reportedDroppedMessages
+    ctx.reportedDroppedMessages= dropped
+
+    l.mtxSeverity.RLock()
+    isEnabled := WarningSeverity.IsGreaterOrEqualThan( l.severity)
+    l.mtxSeverity.RUnlock()
+    if !isEnabled {
+        return
+    }
+    text := fmt.Sprintf("%d log messages were dropped because a log queue was full", numDropped)
+    message := LogMessage{ text: text,
+                           severity: WarningSeverity,
+                           messageType: LogMessageType,
+                           timestamp: time.Now(), }
+    // If the warning is dropped too, it is not counted: that would be reported again and again.
+    dispatchMessage( ctx, message, isBlocking)
+}
@@ -0,0 +1,129 @@
+package dmlog
+
+import "strconv"
+import "strings"
+import "testing"
+import "time"
+
+//--------------------------------------------------------------------------------------------------
+// A sink blocking the dispatcher while it is added, until chRelease is closed.
+type blockingAddLogMessageSink struct {
+    recordingLogMessageSink
+    chStarted chan struct{}
+    chRelease chan struct{}
+}
+
+// Called by the dispatcher when the sink is added.
+func (b *blockingAddLogMessageSink) setErrorReporter( reporter func(error)) {
+    close( b.chStarted)
+    <- b.chRelease
+    b.recordingLogMessageSink.setErrorReporter( reporter)
+}
+
+/* Creates a logger with a recording sink, and blocks its dispatcher until the returned channel is
+   closed, so that the channel of the logger can be filled. */
+func newBlockedLogger( t *testing.T) (*Logger, *recordingLogMessageSink, chan struct{}) {
+    logger := NewLogger()
+    sink := &recordingLogMessageSink{ BaseLogMessageSink: NewBaseLogMessageSink( DebugSeverity, false) }
+    if _, err := logger.AddSink( sink); err!=nil {
+        t.Fatal(t.Name(),`AddSink() failed:`,err)
+    }
+    blockingSink := &blockingAddLogMessageSink{ chStarted: make( chan struct{}), 
+                                                chRelease: make( chan struct{}), }
+    blockingSink.BaseLogMessageSink= NewBaseLogMessageSink( DebugSeverity, false)
+    go logger.AddSink( blockingSink)
+    <- blockingSink.chStarted
+    return logger, sink, blockingSink.chRelease
+}
+
+//--------------------------------------------------------------------------------------------------
+func TestBackpressureDropNewest( t *testing.T) {
+    logger, sink, chRelease := newBlockedLogger( t)
+    if err := logger.SetBackpressure( Backpressure{ Policy: DropNewestBackpressure}); err!=nil {
+        t.Error(t.Name(),`SetBackpressure() failed:`,err)
+    }
+    numMessages := defaultCapChLogMessages+ 50
+    for indx:=0; indx<numMessages; indx++ {
+        logger.Info( indx)
+    }
+    if logger.DroppedMessages()!=50 {
+        t.Error(t.Name(),`Unexpected dropped messages:`,logger.DroppedMessages())
+    }
+    close( chRelease)
+    logger.Flush()
+    logger.Terminate()
+
+    if len(sink.texts)!=defaultCapChLogMessages+1 {
+        t.Error(t.Name(),`Unexpected number of messages:`,len(sink.texts))
+        return
+    }
+    if sink.texts[0]!="0" || sink.texts[defaultCapChLogMessages-1]!=strconv.Itoa( defaultCapChLogMessages-1) {
+        t.Error(t.Name(),`Unexpected messages:`,sink.texts[0],sink.texts[defaultCapChLogMessages-1])
+    }
+    if !strings.HasPrefix( sink.texts[defaultCapChLogMessages], "50 log messages were dropped") {
+        t.Error(t.Name(),`Unexpected report:`,sink.texts[defaultCapChLogMessages])
+    }
+}
+
+//--------------------------------------------------------------------------------------------------
+func TestBackpressureDropOldest( t *testing.T) {
+    logger, sink, chRelease := newBlockedLogger( t)
+    logger.SetBackpressure( Backpressure{ Policy: DropOldestBackpressure})
+    numMessages := defaultCapChLogMessages+ 50
+    for indx:=0; indx<numMessages; indx++ {
+        logger.Info( indx)
+    }
+    close( chRelease)
+    logger.Terminate()
+
+    if logger.DroppedMessages()!=50 || len(sink.texts)!=defaultCapChLogMessages+1 {
+        t.Error(t.Name(),`Unexpected messages:`,logger.DroppedMessages(),len(sink.texts))
+        return
+    }
+    if sink.texts[0]!="50" || sink.texts[defaultCapChLogMessages-1]!=strconv.Itoa( numMessages-1) {
+        t.Error(t.Name(),`Unexpected messages:`,sink.texts[0],sink.texts[defaultCapChLogMessages-1])
+    }
+}
+
+//--------------------------------------------------------------------------------------------------
+func TestBackpressureTimeoutAndSeverity( t *testing.T) {
+    logger, sink, chRelease := newBlockedLogger( t)
+    logger.SetBackpressure( Backpressure{ Policy: BlockTimeoutBackpressure, Timeout: time.Millisecond})
+    for indx:=0; indx<defaultCapChLogMessages; indx++ {
+        logger.Info( indx)
+    }
+    if logger.Info("Timed out") {
+        t.Error(t.Name(),`The message was not dropped`)
+    }
+
+    logger.SetBackpressure( Backpressure{ Policy: DropBelowSeverityBackpressure, MinSeverity: WarningSeverity})
+    if logger.Debug("Debug message") {
+        t.Error(t.Name(),`The debug message was not dropped`)
+    }
+    chWarningSent := make( chan struct{})
+    go func() {
+        logger.Warn("Warning message")
+        close( chWarningSent)
+    }()
+    select {
+        case <- chWarningSent:
+            t.Error(t.Name(),`The warning message did not wait`)
+        case <- time.After( 10*time.Millisecond):
+    }
+    close( chRelease)
+    <- chWarningSent
+    logger.Terminate()
+
+    if logger.DroppedMessages()!=2 {
+        t.Error(t.Name(),`Unexpected dropped messages:`,logger.DroppedMessages())
+    }
+    last := len(sink.texts)-1
+    if last<1 || sink.texts[last-1]!="Warning message" || 
+       !strings.HasPrefix( sink.texts[last], "2 log messages were dropped") {
+        t.Error(t.Name(),`Unexpected messages:`,sink.texts)
+    }
+
+    if err := logger.SetBackpressure( Backpressure{ Policy: BlockTimeoutBackpressure}); err==nil {
+        t.Error(t.Name(),`SetBackpressure() accepted a zero timeout`)
+    }
+}
@@ -1,11 +1,18 @@
 package dmlog
 
 import "fmt"
+import "io"
 import "os"
 
 // Implementation of a log sink that prints messages on the console.
 type consoleLogMessageSink struct {
     BaseLogMessageSink
+    // Replace the standard streams when not nil, e.g. in tests.
+    stdout io.Writer
+    stderr io.Writer
+    // In split mode, log messages having at least stderrThreshold severity go to stderr.
+    isSplit bool
+    stderrThreshold LogSeverity
 }
 
 /* Adds a log message sink that prints on the console.
@@ -13,8 +20,52 @@ type consoleLogMessageSink struct {
    threshold.
  */
 func AddConsoleSink( threshold LogSeverity) (MessageSinkId, error) {
+    return DefaultLogger().AddConsoleSink( threshold)
+}
+
+// Adds to the logger a log message sink that prints on the console.
+func (l *Logger) AddConsoleSink( threshold LogSeverity) (MessageSinkId, error) {
+    msgSink := newConsoleLogMessageSink( threshold, false)
+    return l.addMessageSink( msgSink)
+}
+
+/* Adds a log message sink that prints on the console, in split mode: log messages having at 
+   least the stderrThreshold severity, e.g. WarningSeverity, are printed on the standard error, 
+   the other messages on the standard output.  Print messages always go to the standard output.
+   In case error is nil, the returned message sink id can be used later to modify the severity
+   threshold.
+ */
+func AddConsoleSplitSink( threshold LogSeverity, stderrThreshold LogSeverity) (MessageSinkId, error) {
+    return DefaultLogger().AddConsoleSplitSink( threshold, stderrThreshold)
+}
+
+// Adds to the logger a console sink in split mode, see AddConsoleSplitSink().
+func (l *Logger) AddConsoleSplitSink( threshold LogSeverity, 
+                                      stderrThreshold LogSeverity) (MessageSinkId, error) {
     msgSink := newConsoleLogMessageSink( threshold, false)
-    return addMessageSink( msgSink)
+    msgSink.isSplit= true
+    msgSink.stderrThreshold= stderrThreshold
+    return l.addMessageSink( msgSink)
+}
+
+/* Enables or disables the split mode of the given console sink, see AddConsoleSplitSink().
+   Returns false if sinkId is not a console sink. */
+func SetConsoleSplit( sinkId MessageSinkId, isSplit bool, stderrThreshold LogSeverity) bool {
+    return DefaultLogger().SetConsoleSplit( sinkId, isSplit, stderrThreshold)
+}
+
+// Enables or disables the split mode of a console sink, see SetConsoleSplit().
+func (l *Logger) SetConsoleSplit( sinkId MessageSinkId, 
+                                  isSplit bool, 
+                                  stderrThreshold LogSeverity) bool {
+    return l.reqSinkFunction( sinkId, func( sink LogMessageSink) bool {
+                                            consoleSink, ok := sink.(*consoleLogMessageSink)
+                                            if ok {
+                                                consoleSink.isSplit= isSplit
+                                                consoleSink.stderrThreshold= stderrThreshold
+                                            }
+                                            return ok
+                                        })
 }
 
 //--------------------------------------------------------------------------------------------------
@@ -23,7 +74,7 @@ func newConsoleLogMessageSink( threshold LogSeverity, isFrequentFlush bool) *con
         LogMessageType: defaultLogFormat(),
         PrintMessageType: defaultPrintFormat(),
     }
-    obj := consoleLogMessageSink{ BaseLogMessageSink  {
+    obj := consoleLogMessageSink{ BaseLogMessageSink:  BaseLogMessageSink{
                                     threshold:threshold,                                                       
                                     isFrequentFlush:isFrequentFlush,
                                     messageTypeToFormat: messageTypeToFormat,} } 
@@ -43,21 +94,36 @@ func (c *consoleLogMessageSink) Severity() LogSeverity {
 //--------------------------------------------------------------------------------------------------
 func (c *consoleLogMessageSink) OnLogMessage( msg *LogMessage) {
     if msg.severity.IsGreaterOrEqualThan( c.threshold) {
-        format, ok := c.messageTypeToFormat[ msg.messageType ]
-        if ok {
-            fmt.Print( formatLogMessage( msg, &format) )
-        } else {
-            fmt.Println( msg.text)
+        if _, err := fmt.Fprint( c.output( msg), c.FormatMessage( msg) ); err!=nil {
+            c.ReportError( fmt.Errorf("failed while trying to print on the console:%w",err))
         }
         if c.isFrequentFlush {
-            c.flush()
+            c.Flush()
         }
     }
 }
     
 //--------------------------------------------------------------------------------------------------
-func (c *consoleLogMessageSink) flush() {
+// Retrieves where the message is printed: the standard error is used only in split mode.
+func (c *consoleLogMessageSink) output( msg *LogMessage) io.Writer {
+    if c.isSplit && msg.messageType!=PrintMessageType && 
+       msg.severity.IsGreaterOrEqualThan( c.stderrThreshold) {
+        if c.stderr!=nil {
+            return c.stderr
+        }
+        return os.Stderr
+    }
+    if c.stdout!=nil {
+        return c.stdout
+    }
+    return os.Stdout
+}
+
+//--------------------------------------------------------------------------------------------------
+// The standard streams are not buffered, and Sync() fails when they are a terminal or a pipe.
+func (c *consoleLogMessageSink) Flush() {
     os.Stdout.Sync()    
+    os.Stderr.Sync()    
 }
     
 //--------------------------------------------------------------------------------------------------
@@ -66,13 +132,13 @@ func (c *consoleLogMessageSink) SetFlush( isFrequentFlush bool) {
 }
 
 //--------------------------------------------------------------------------------------------------
-func (c *consoleLogMessageSink) setSinkFormat( messageType MessageType, 
+func (c *consoleLogMessageSink) SetSinkFormat( messageType MessageType, 
                                                 format LogFormatItems) bool {
-    return c.BaseLogMessageSink.setSinkFormat( messageType, format)
+    return c.BaseLogMessageSink.SetSinkFormat( messageType, format)
 }
     
 //--------------------------------------------------------------------------------------------------
-func (c *consoleLogMessageSink) terminate() {
-    c.flush()
+func (c *consoleLogMessageSink) Terminate() {
+    c.Flush()
 }
 
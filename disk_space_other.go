@@ -0,0 +1,14 @@
+//go:build !linux && !darwin && !freebsd
+// +build !linux,!darwin,!freebsd
+
+package dmlog
+
+import "errors"
+
+// The free disk space cannot be retrieved on this platform.
+const isDiskFreeSpaceSupported bool = false
+
+// Retrieves the disk space available on the file system of dirPath: not supported.
+func diskFreeSpace( dirPath string) (Bytes, error) {
+    return 0, errors.New("free disk space not supported on this platform")
+}
@@ -0,0 +1,18 @@
+//go:build linux || darwin || freebsd
+// +build linux darwin freebsd
+
+package dmlog
+
+import "syscall"
+
+// The free disk space can be retrieved on this platform.
+const isDiskFreeSpaceSupported bool = true
+
+// Retrieves the disk space available to unprivileged users on the file system of dirPath.
+func diskFreeSpace( dirPath string) (Bytes, error) {
+    var stat syscall.Statfs_t
+    if err := syscall.Statfs( dirPath, &stat); err!=nil {
+        return 0, err
+    }
+    return Bytes( uint64(stat.Bavail)*uint64(stat.Bsize)), nil
+}
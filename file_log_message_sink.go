@@ -1,12 +1,28 @@
 package dmlog
 
+import "errors"
 import "fmt"
 import "os"
+import "os/signal"
+import "sync"
+import "time"
 
 // Implementation of a log sink that prints messages to a single file.
 type fileLogMessageSink struct {
     BaseLogMessageSink
+    filename string
     outFile *os.File
+
+    // How often the file path is checked for being moved or deleted; zero disables the check.
+    pathCheckInterval time.Duration
+    lastPathCheck time.Time
+}
+
+/* Implemented by the sinks writing to a file that can be closed and opened again, e.g. after an
+   external tool like logrotate renamed it. */
+type ReopenableLogMessageSink interface {
+    // Closes the file, then opens again the same path, appending to it if it exists.
+    Reopen() error
 }
 
 /* Adds a log message sink that write messages to the specified file.
@@ -14,14 +30,14 @@ type fileLogMessageSink struct {
    In case error is nil, the returned message sink id can be used later to modify the severity 
    threshold.*/
 func AddFileSinkCreate( filename string, threshold LogSeverity) (MessageSinkId, error) {
-    return AddFileSink( filename, false, threshold, false)
+    return DefaultLogger().AddFileSink( filename, false, threshold, false)
 }
 
 /* Adds a log message sink that append messages to the specified file.
    In case error is nil, the returned message sink id can be used later to modify the severity 
    threshold.*/
 func AddFileSinkAppend( filename string, threshold LogSeverity) (MessageSinkId, error) {
-    return AddFileSink( filename, true, threshold, false)
+    return DefaultLogger().AddFileSink( filename, true, threshold, false)
 }
 
 /* Adds a log message sink that prints on the specified file.
@@ -31,11 +47,133 @@ func AddFileSink( filename string,
                   appendExisting bool, 
                   threshold LogSeverity, 
                   isFrequentFlush bool) (MessageSinkId, error) {
+    return DefaultLogger().AddFileSink( filename, appendExisting, threshold, isFrequentFlush)
+}
+
+// Adds to the logger a sink that writes messages to the specified file, see AddFileSinkCreate().
+func (l *Logger) AddFileSinkCreate( filename string, threshold LogSeverity) (MessageSinkId, error) {
+    return l.AddFileSink( filename, false, threshold, false)
+}
+
+// Adds to the logger a sink that appends messages to the specified file, see AddFileSinkAppend().
+func (l *Logger) AddFileSinkAppend( filename string, threshold LogSeverity) (MessageSinkId, error) {
+    return l.AddFileSink( filename, true, threshold, false)
+}
+
+// Adds to the logger a log message sink that prints on the specified file, see AddFileSink().
+func (l *Logger) AddFileSink( filename string, 
+                              appendExisting bool, 
+                              threshold LogSeverity, 
+                              isFrequentFlush bool) (MessageSinkId, error) {
     msgSink, err := newFileLogMessageSink( filename, appendExisting, threshold, isFrequentFlush)
     if err!=nil {
         return 0, err
     }
-    return addMessageSink( msgSink)
+    return l.addMessageSink( msgSink)
+}
+
+/* Closes and reopens the files of all the sinks implementing ReopenableLogMessageSink, like the 
+   file sinks.  It is meant to be called after an external tool like logrotate renamed the files.*/
+func ReopenFileSinks() error {
+    return DefaultLogger().ReopenFileSinks()
+}
+
+/* Starts calling ReopenFileSinks() every time the process receives one of the given signals,
+   SIGHUP if none is given.  The returned function stops the signal handling.
+   The files of the current default logger are reopened, also after Init() or Reset() replaced it;
+   the signals are handled until the returned function is called.
+   The errors are reported to the error handler, see SetErrorHandler().
+   On platforms without SIGHUP, e.g. js, the signals must be given, otherwise nothing is handled. */
+func HandleReopenSignal( signals ...os.Signal) func() {
+    return handleReopenSignal( DefaultLogger, signals)
+}
+
+/* Enables the periodic check of the path of the given file sink: when the file was moved or 
+   deleted, the path is opened again.  The check is done before writing a message, at most once
+   per interval; a zero interval disables it.  Returns false if sinkId is not a file sink. */
+func SetFileSinkPathCheck( sinkId MessageSinkId, interval time.Duration) bool {
+    return DefaultLogger().SetFileSinkPathCheck( sinkId, interval)
+}
+
+// Reopens the files of the logger sinks, see ReopenFileSinks().
+func (l *Logger) ReopenFileSinks() error {
+    if l.IsTerminated() {
+        return errors.New( fatalLogTerminated)
+    }
+    return l.reqReopenSinks( false)
+}
+
+/* Reopens the files of the logger sinks when a signal is received, see HandleReopenSignal().
+   Once the logger is terminated, the signals are ignored until the returned function is called. */
+func (l *Logger) HandleReopenSignal( signals ...os.Signal) func() {
+    return handleReopenSignal( func() *Logger { return l }, signals)
+}
+
+// Enables the periodic check of the path of a file sink, see SetFileSinkPathCheck().
+func (l *Logger) SetFileSinkPathCheck( sinkId MessageSinkId, interval time.Duration) bool {
+    return l.reqSinkFunction( sinkId, func( sink LogMessageSink) bool {
+                                            fileSink, ok := sink.(*fileLogMessageSink)
+                                            if ok {
+                                                fileSink.pathCheckInterval= interval
+                                            }
+                                            return ok
+                                        })
+}
+
+//--------------------------------------------------------------------------------------------------
+/* Reopens the files of the logger returned by getLogger when a signal is received.  The signals 
+   stay handled until the returned function is called: stopping earlier would restore their 
+   default action, e.g. SIGHUP terminates the process. */
+func handleReopenSignal( getLogger func() *Logger, signals []os.Signal) func() {
+    if len(signals)==0 {
+        signals= defaultReopenSignals
+    }
+    if len(signals)==0 {
+        return func() {}
+    }
+    chSignal := make( chan os.Signal, 1)
+    chStop := make( chan struct{})
+    signal.Notify( chSignal, signals...)
+    go reopenOnSignal( chSignal, chStop, getLogger)
+    var stopOnce sync.Once
+    return func() {
+        stopOnce.Do( func() {
+            signal.Stop( chSignal)
+            close( chStop)
+        })
+    }
+}
+
+//--------------------------------------------------------------------------------------------------
+// Reopens the files of the logger returned by getLogger on each signal, until chStop is closed.
+func reopenOnSignal( chSignal <-chan os.Signal, chStop <-chan struct{}, getLogger func() *Logger) {
+    for {
+        select {
+            case <- chSignal:
+                /* There is no caller to return the errors to: they go to the error handler.  A 
+                   terminated logger ignores the request. */
+                getLogger().reqReopenSinks( true)
+            case <- chStop:
+                return
+        }
+    }
+}
+
+//--------------------------------------------------------------------------------------------------
+// Opens the log file, truncating or appending to the existing file.
+func openLogFile( filename string, appendExisting bool) (*os.File, error) {
+    if appendExisting {
+        file, err := os.OpenFile( filename, os.O_CREATE | os.O_APPEND | os.O_WRONLY, 0666) 
+        if err != nil {
+            return nil, fmt.Errorf("failed while trying to append to the file %s:%s",filename,err)
+        }        
+        return file, nil
+    } 
+    file, err := os.Create( filename)
+    if err != nil {
+        return nil, fmt.Errorf("failed while trying to create the file %s:%s",filename,err)
+    }        
+    return file, nil
 }
 
 //--------------------------------------------------------------------------------------------------
@@ -47,24 +185,16 @@ func newFileLogMessageSink( filename string,
         LogMessageType: defaultLogFormat(),
         PrintMessageType: defaultPrintFormat(),
     }
-    var file *os.File
-    var err error
-    if appendExisting {
-        file, err = os.OpenFile( filename, os.O_CREATE | os.O_APPEND | os.O_WRONLY, 0) 
-        if err != nil {
-            return nil, fmt.Errorf("failed while trying to append to the file %s:%s",filename,err)
-        }        
-    } else {
-        file, err = os.Create( filename)
-        if err != nil {
-            return nil, fmt.Errorf("failed while trying to create the file %s:%s",filename,err)
-        }        
+    file, err := openLogFile( filename, appendExisting)
+    if err != nil {
+        return nil, err
     }
 
     obj := fileLogMessageSink{ BaseLogMessageSink: BaseLogMessageSink {
                                     threshold:threshold,                                                       
                                     isFrequentFlush:isFrequentFlush,
                                     messageTypeToFormat:messageTypeToFormat, },
+                                 filename: filename,
                                  outFile: file, } 
     return &obj,nil
 }
@@ -82,23 +212,35 @@ func (f *fileLogMessageSink) Severity() LogSeverity {
 //--------------------------------------------------------------------------------------------------
 func (f *fileLogMessageSink) OnLogMessage( msg *LogMessage) {
     if msg.severity.IsGreaterOrEqualThan( f.threshold) {
-        format, ok := f.messageTypeToFormat[ msg.messageType ]
-        if ok {
-            fmt.Fprint( f.outFile, formatLogMessage( msg, &format) )
-        } else {
-            fmt.Fprintln( f.outFile, msg.text)
+        f.checkPath()
+        // The file is missing if it could not be opened: it is tried again for each message.
+        if f.outFile==nil {
+            if err := f.Reopen(); err!=nil {
+                f.ReportError( fmt.Errorf("message dropped:%w",err))
+                return
+            }
+        }
+        if _, err := fmt.Fprint( f.outFile, f.FormatMessage( msg) ); err!=nil {
+            f.ReportError( fmt.Errorf("failed while trying to write to the file %s:%w",f.filename,err))
         }
         if f.isFrequentFlush {
-            f.flush()
+            f.Flush()
         }
     }
 }
     
 //--------------------------------------------------------------------------------------------------
-func (f *fileLogMessageSink) flush() {
+// Same as FlushError(), the error is discarded.
+func (f *fileLogMessageSink) Flush() {
+    f.FlushError()
+}
+
+//--------------------------------------------------------------------------------------------------
+func (f *fileLogMessageSink) FlushError() error {
     if f.outFile != nil {
-        f.outFile.Sync()
+        return f.outFile.Sync()
     }
+    return nil
 }
     
 //--------------------------------------------------------------------------------------------------
@@ -107,15 +249,58 @@ func (f *fileLogMessageSink) SetFlush( isFrequentFlush bool) {
 }
 
 //--------------------------------------------------------------------------------------------------
-func (f *fileLogMessageSink) setSinkFormat( messageType MessageType, 
+func (f *fileLogMessageSink) SetSinkFormat( messageType MessageType, 
                                             format LogFormatItems) bool {
-    return f.BaseLogMessageSink.setSinkFormat( messageType, format)
+    return f.BaseLogMessageSink.SetSinkFormat( messageType, format)
 }
     
 //--------------------------------------------------------------------------------------------------
-func (f *fileLogMessageSink) terminate() {
+func (f *fileLogMessageSink) Terminate() {
     if f.outFile != nil {
-        f.outFile.Close()
+        if err := f.outFile.Close(); err!=nil {
+            f.ReportError( fmt.Errorf("failed while trying to close the file %s:%w",f.filename,err))
+        }
         f.outFile = nil
     }
 }  
+
+//--------------------------------------------------------------------------------------------------
+/* Opens the path again, then closes the previous file.  If the path cannot be opened, e.g. its
+   directory was removed, the previous file is kept. */
+func (f *fileLogMessageSink) Reopen() error {
+    file, err := openLogFile( f.filename, true)
+    if err != nil {
+        return err
+    }
+    if f.outFile != nil {
+        if err := f.outFile.Close(); err!=nil {
+            f.ReportError( fmt.Errorf("failed while trying to close the file %s:%w",f.filename,err))
+        }
+    }
+    f.outFile= file
+    return nil
+}
+
+//--------------------------------------------------------------------------------------------------
+// Reopens the file if its path was moved or deleted.  It is done at most once per interval.
+func (f *fileLogMessageSink) checkPath() {
+    if f.pathCheckInterval<=0 || f.outFile==nil {
+        return
+    }
+    now := time.Now()
+    if now.Sub( f.lastPathCheck) < f.pathCheckInterval {
+        return
+    }
+    f.lastPathCheck= now
+
+    pathInfo, err := os.Stat( f.filename)
+    if err==nil {
+        fileInfo, err := f.outFile.Stat()
+        if err==nil && os.SameFile( pathInfo, fileInfo) {
+            return
+        }
+    }
+    if err := f.Reopen(); err!=nil {
+        f.ReportError( err)
+    }
+}
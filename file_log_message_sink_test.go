@@ -1,8 +1,10 @@
 package dmlog
 
+import "errors"
 import "fmt"
 import "io/ioutil"
 import "os"
+import "path/filepath"
 import "testing"
 import "time"
 
@@ -28,7 +30,9 @@ func TestFileSinkCreate( t *testing.T) {
     SetSeverity( DebugSeverity)
     Debug("Debug message")
     Info("Info message")
-    time.Sleep(200*time.Millisecond)
+    if err := Flush(); err!=nil {
+        t.Error(t.Name(),`Flush() failed:`,err)            
+    }
 
     fileInfo, err := os.Stat(filename)
     if err!=nil {
@@ -38,7 +42,7 @@ func TestFileSinkCreate( t *testing.T) {
     if fileInfo.Size()<=0 {
         t.Error(t.Name(),`Unexpected empty output file:`,filename)            
     }    
-    time.Sleep(100*time.Millisecond)
+    Flush()
     ClearSinks()
 }
 
@@ -79,7 +83,9 @@ func TestFileSinkAppend( t *testing.T) {
     }
 
     SetMessageSinkSeverity( sinkId, InfoSeverity)
-    time.Sleep(200*time.Millisecond)
+    if err := Flush(); err!=nil {
+        t.Error(t.Name(),`Flush() failed:`,err)            
+    }
 
     fileInfo, err := os.Stat(filename)
     if err!=nil {
@@ -91,10 +97,66 @@ func TestFileSinkAppend( t *testing.T) {
     }    
 
     SetSeverity( DebugSeverity)
-    time.Sleep(100*time.Millisecond)
+    Flush()
     ClearSinks()
 }
 
+//--------------------------------------------------------------------------------------------------
+func TestReopenFileSinks( t *testing.T) {
+    tempDirName, err := ioutil.TempDir("", "file_sink_test")
+    if err != nil {
+        t.Error(t.Name(),`TempDir() failed:`,err)            
+        return
+    }
+    defer os.RemoveAll( tempDirName)
+    filename := filepath.Join( tempDirName, "log.txt")
+    rotatedFilename := filepath.Join( tempDirName, "log.txt.1")
+
+    logger := NewLogger()
+    defer logger.Terminate()
+    sinkId, err := logger.AddFileSinkCreate( filename, DebugSeverity)
+    if err!=nil {
+        t.Error(t.Name(),`AddFileSinkCreate() failed:`,err)            
+        return
+    }
+    logger.SetSinkOutputFormat( sinkId, LogMessageType, TextFmt)
+
+    logger.Debug("Before rotation")
+    logger.Flush()
+    if err := os.Rename( filename, rotatedFilename); err!=nil {
+        t.Error(t.Name(),`Rename() failed:`,err)            
+        return
+    }
+    logger.Debug("Still in the rotated file")
+    if err := logger.ReopenFileSinks(); err!=nil {
+        t.Error(t.Name(),`ReopenFileSinks() failed:`,err)            
+    }
+    logger.Debug("After rotation")
+
+    // The path check detects the second rotation.
+    if ! logger.SetFileSinkPathCheck( sinkId, time.Nanosecond) {
+        t.Error(t.Name(),`SetFileSinkPathCheck() failed`)            
+    }
+    logger.Flush()
+    if err := os.Remove( filename); err!=nil {
+        t.Error(t.Name(),`Remove() failed:`,err)            
+        return
+    }
+    logger.Debug("After removal")
+    logger.Flush()
+
+    for filename, want := range map[string]string{ 
+                                    rotatedFilename: "Before rotation \nStill in the rotated file \n",
+                                    filename: "After removal \n", } {
+        content, err := ioutil.ReadFile( filename)
+        if err!=nil {
+            t.Error(t.Name(),`ReadFile() failed:`,err)            
+        } else if string(content)!=want {
+            t.Errorf("%s content of %s: got %q want %q", t.Name(), filename, string(content), want)
+        }
+    }
+}
+
 /* Example of how to log to a file that is overwritten at every execution. */
 func ExampleAddFileSinkCreate() {
     _, err := AddFileSinkCreate( "log.txt", DebugSeverity) 
@@ -104,7 +166,7 @@ func ExampleAddFileSinkCreate() {
     }
     
     Debug("Debug message")
-    time.Sleep(100*time.Millisecond)
+    Flush()
     ClearSinks()    
 }
 
@@ -119,3 +181,162 @@ func ExampleAddFileSinkAppend() {
     
     Debug("Debug message")  
 }
+
+//--------------------------------------------------------------------------------------------------
+// A sink whose Reopen() always fails.
+type failingReopenLogMessageSink struct {
+    recordingLogMessageSink
+}
+
+func (f *failingReopenLogMessageSink) Reopen() error {
+    return errors.New("reopen failed")
+}
+
+//--------------------------------------------------------------------------------------------------
+func TestReopenSignalErrors( t *testing.T) {
+    logger := NewLogger()
+    defer logger.Terminate()
+    chErrors := make( chan error, 1)
+    logger.SetErrorHandler( func( sinkId MessageSinkId, err error) { chErrors <- err })
+    sink := &failingReopenLogMessageSink{}
+    sink.BaseLogMessageSink= NewBaseLogMessageSink( DebugSeverity, false)
+    sinkId, err := logger.AddSink( sink)
+    if err!=nil {
+        t.Error(t.Name(),`AddSink() failed:`,err)
+        return
+    }
+
+    // As done by the goroutine of HandleReopenSignal().
+    if err := logger.reqReopenSinks( true); err!=nil {
+        t.Error(t.Name(),`The error was returned:`,err)
+    }
+    select {
+        case err := <- chErrors:
+            if err.Error()!="reopen failed" {
+                t.Error(t.Name(),`Unexpected error:`,err)
+            }
+        case <- time.After( 10*time.Second):
+            t.Error(t.Name(),`The error was not reported`)
+    }
+    if stats, _ := logger.SinkStatistics( sinkId); stats.Errors!=1 {
+        t.Error(t.Name(),`Unexpected statistics:`,stats)
+    }
+}
+
+//--------------------------------------------------------------------------------------------------
+func TestReopenFileSinkMissingDir( t *testing.T) {
+    tempDirName, err := ioutil.TempDir("", "file_sink_test")
+    if err != nil {
+        t.Error(t.Name(),`TempDir() failed:`,err)            
+        return
+    }
+    defer os.RemoveAll( tempDirName)
+    dirName := filepath.Join( tempDirName, "logs")
+    if err := os.Mkdir( dirName, 0777); err!=nil {
+        t.Error(t.Name(),`Mkdir() failed:`,err)            
+        return
+    }
+    filename := filepath.Join( dirName, "log.txt")
+
+    logger := NewLogger()
+    defer logger.Terminate()
+    sinkId, err := logger.AddFileSinkCreate( filename, DebugSeverity)
+    if err!=nil {
+        t.Error(t.Name(),`AddFileSinkCreate() failed:`,err)            
+        return
+    }
+    logger.SetSinkOutputFormat( sinkId, LogMessageType, TextFmt)
+    if err := os.RemoveAll( dirName); err!=nil {
+        t.Skip(t.Name(),`The directory of an open file cannot be removed:`,err)
+    }
+    if err := logger.ReopenFileSinks(); err==nil {
+        t.Error(t.Name(),`ReopenFileSinks() succeeded on a missing directory`)            
+    }
+    // The previous file is kept.
+    logger.Debug("Into the removed file")
+    if err := logger.Flush(); err!=nil {
+        t.Error(t.Name(),`Flush() failed:`,err)            
+    }
+
+    if err := os.Mkdir( dirName, 0777); err!=nil {
+        t.Error(t.Name(),`Mkdir() failed:`,err)            
+        return
+    }
+    if err := logger.ReopenFileSinks(); err!=nil {
+        t.Error(t.Name(),`ReopenFileSinks() failed:`,err)            
+    }
+    logger.Debug("After restore")
+    logger.Flush()
+    if stats, _ := logger.SinkStatistics( sinkId); stats.Errors!=0 {
+        t.Error(t.Name(),`Unexpected errors:`,stats.LastError)            
+    }
+    content, err := ioutil.ReadFile( filename)
+    if err!=nil || string(content)!="After restore \n" {
+        t.Error(t.Name(),`Unexpected content:`,string(content),err)            
+    }
+}
+
+//--------------------------------------------------------------------------------------------------
+func TestFileSinkOpenRetry( t *testing.T) {
+    tempDirName, err := ioutil.TempDir("", "file_sink_test")
+    if err != nil {
+        t.Error(t.Name(),`TempDir() failed:`,err)            
+        return
+    }
+    defer os.RemoveAll( tempDirName)
+    filename := filepath.Join( tempDirName, "log.txt")
+    sink, err := newFileLogMessageSink( filename, false, DebugSeverity, false)
+    if err!=nil {
+        t.Error(t.Name(),`newFileLogMessageSink() failed:`,err)            
+        return
+    }
+    defer sink.Terminate()
+    sink.SetSinkFormat( LogMessageType, LogFormatItems{ TextFmt})
+
+    // A sink without a file opens it again before writing.
+    sink.Terminate()
+    message := LogMessage{ text: "After the retry", severity: InfoSeverity, messageType: LogMessageType, }
+    sink.OnLogMessage( &message)
+    sink.Flush()
+    content, err := ioutil.ReadFile( filename)
+    if err!=nil || string(content)!="After the retry \n" {
+        t.Error(t.Name(),`Unexpected content:`,string(content),err)            
+    }
+}
+
+//--------------------------------------------------------------------------------------------------
+func TestReopenSignalAfterReset( t *testing.T) {
+    defer Reset()
+    chSignal := make( chan os.Signal, 1)
+    chStop := make( chan struct{})
+    defer close( chStop)
+    chExited := make( chan struct{})
+    go func() {
+        reopenOnSignal( chSignal, chStop, DefaultLogger)
+        close( chExited)
+    }()
+
+    // The terminated default logger ignores the signal, that stays handled.
+    Terminate()
+    chSignal <- os.Interrupt
+    Init()
+    chErrors := make( chan error, 1)
+    SetErrorHandler( func( sinkId MessageSinkId, err error) { chErrors <- err })
+    sink := &failingReopenLogMessageSink{}
+    sink.BaseLogMessageSink= NewBaseLogMessageSink( DebugSeverity, false)
+    if _, err := AddSink( sink); err!=nil {
+        t.Error(t.Name(),`AddSink() failed:`,err)
+        return
+    }
+    chSignal <- os.Interrupt
+    select {
+        case err := <- chErrors:
+            if err.Error()!="reopen failed" {
+                t.Error(t.Name(),`Unexpected error:`,err)
+            }
+        case <- chExited:
+            t.Error(t.Name(),`The signal handling stopped with the terminated logger`)
+        case <- time.After( 10*time.Second):
+            t.Error(t.Name(),`The new default logger was not reopened`)
+    }
+}
@@ -0,0 +1,511 @@
+package dmlog
+
+import "bufio"
+import "bytes"
+import "fmt"
+import "io"
+import "io/ioutil"
+import "net/http"
+import "os"
+import "strings"
+import "time"
+
+const defaultHTTPQueueCapacity int = 1000
+const defaultHTTPMaxBatchMessages int = 100
+const defaultHTTPMaxBatchSize KBytes = 1024
+const defaultHTTPMaxBatchLatency = time.Second
+const defaultHTTPTimeout = 10*time.Second
+const defaultHTTPMaxRetries int = 3
+const defaultHTTPMinRetryDelay = 500*time.Millisecond
+const defaultHTTPMaxRetryDelay = 30*time.Second
+
+// How the messages of a batch are put into the body of the request.
+type HTTPBatchFormat int8
+
+// The supported batch formats.  Each message is a JSON object, as for the JSONEncoding.
+const (
+    JSONLinesHTTPBatchFormat HTTPBatchFormat = iota // One object per line, "application/x-ndjson".
+    JSONArrayHTTPBatchFormat // An array of objects, "application/json".
+)
+
+/* What the HTTP sink does with a batch that could not be delivered because the endpoint is not
+   available, i.e. a transport error or a 5xx status.  A batch refused by the endpoint with any 
+   other status, e.g. 400 or 413, is dropped, as posting it again would fail again. */
+type HTTPFailurePolicy int8
+
+// The supported failure policies.
+const (
+    DropHTTPFailurePolicy HTTPFailurePolicy = iota // The batch is lost.
+    SpillHTTPFailurePolicy // The batch is stored into the spill file and delivered later.
+)
+
+// Implementation of a log sink that posts batches of messages to an HTTP endpoint.
+type httpLogMessageSink struct {
+    BaseLogMessageSink
+
+    url               string
+    options           HTTPSinkOptions
+    batch             []string
+    batchSize         Bytes
+    // Fires when the oldest message of the batch waited MaxBatchLatency.
+    batchTimer        *time.Timer
+    // After a batch could not be delivered, no request is sent before this time.
+    pauseUntil        time.Time
+    spillFile         *os.File
+    spillSize         Bytes
+
+    chStrLog          chan string
+    chReqFlush        chan chan error
+    chReqTerminate    chan struct{}
+    chReplyTerminate  chan struct{}
+}
+
+/* Optional settings of the HTTP sink.
+   The zero value of each member selects its default. */
+type HTTPSinkOptions struct {
+    Format HTTPBatchFormat
+
+    // Added to each request, e.g. the authorization.
+    Headers http.Header
+
+    /* A batch is posted when it has MaxBatchMessages messages, when adding a message would make it
+       larger than MaxBatchSize, or when its oldest message waited MaxBatchLatency. */
+    MaxBatchMessages int
+    MaxBatchSize KBytes
+    MaxBatchLatency time.Duration
+
+    /* The number of messages waiting to be batched by the goroutine of the sink.  The sink keeps its
+       own queue because that goroutine also posts the batches on MaxBatchLatency and waits for the
+       retries, driven by timers.  When this queue is full, the messages wait in the queue of the
+       sink in the logger, then the backpressure policy of the logger applies, see Backpressure. */
+    QueueCapacity int
+
+    // The client posting the requests; by default a client with a 10 seconds timeout.
+    Client *http.Client
+
+    /* When the post fails, or the endpoint replies with a 5xx status, the post is tried again
+       MaxRetries times, after MinRetryDelay doubled after each attempt up to MaxRetryDelay.
+       A negative MaxRetries disables the retries.
+       After a batch could not be delivered, the following batches are not posted, and handled
+       according to the failure policy, until MaxRetryDelay elapsed. */
+    MaxRetries int
+    MinRetryDelay time.Duration
+    MaxRetryDelay time.Duration
+
+    FailurePolicy HTTPFailurePolicy
+
+    /* The file storing the batches not delivered, by the SpillHTTPFailurePolicy.  Its messages are
+       posted before any newer one, as soon as the endpoint is available. */
+    SpillFilename string
+
+    // When the spill file reaches MaxSpillSize, further messages are dropped; default 10 MBytes.
+    MaxSpillSize KBytes
+}
+
+/* Adds a log message sink that posts batches of JSON encoded messages to the given url, e.g. a
+   log collector or a webhook.
+   In case error is nil, the returned message sink id can be used later to modify the severity
+   threshold.*/
+func AddHTTPSink( url string, threshold LogSeverity) (MessageSinkId, error) {
+    return DefaultLogger().AddHTTPSinkWithOptions( url, threshold, HTTPSinkOptions{})
+}
+
+// Same as AddHTTPSink(), with the optional settings, e.g. the batch format and the headers.
+func AddHTTPSinkWithOptions( url string,
+                             threshold LogSeverity,
+                             options HTTPSinkOptions) (MessageSinkId, error) {
+    return DefaultLogger().AddHTTPSinkWithOptions( url, threshold, options)
+}
+
+// Adds to the logger a sink that posts batches of messages, see AddHTTPSink().
+func (l *Logger) AddHTTPSink( url string, threshold LogSeverity) (MessageSinkId, error) {
+    return l.AddHTTPSinkWithOptions( url, threshold, HTTPSinkOptions{})
+}
+
+// Adds to the logger an HTTP sink with the optional settings, see AddHTTPSinkWithOptions().
+func (l *Logger) AddHTTPSinkWithOptions( url string,
+                                         threshold LogSeverity,
+                                         options HTTPSinkOptions) (MessageSinkId, error) {
+    msgSink, err := newHTTPLogMessageSink( url, threshold, options)
+    if err!=nil {
+        return MessageSinkId(0), err
+    }
+    return l.addMessageSink( msgSink)
+}
+
+//--------------------------------------------------------------------------------------------------
+func newHTTPLogMessageSink( url string,
+                            threshold LogSeverity,
+                            options HTTPSinkOptions) (*httpLogMessageSink, error) {
+    if !strings.HasPrefix( url, "http://") && !strings.HasPrefix( url, "https://") {
+        return nil, fmt.Errorf("invalid url %s", url)
+    }
+    if options.Format!=JSONLinesHTTPBatchFormat && options.Format!=JSONArrayHTTPBatchFormat {
+        return nil, fmt.Errorf("invalid batch format %d", options.Format)
+    }
+    if options.FailurePolicy!=DropHTTPFailurePolicy && options.FailurePolicy!=SpillHTTPFailurePolicy {
+        return nil, fmt.Errorf("invalid failure policy %d", options.FailurePolicy)
+    }
+    if options.FailurePolicy==SpillHTTPFailurePolicy && len(options.SpillFilename)==0 {
+        return nil, fmt.Errorf("the spill failure policy needs a spill file")
+    }
+    if options.MaxBatchMessages<0 || options.MaxBatchLatency<0 || options.QueueCapacity<0 ||
+       options.MinRetryDelay<0 || options.MaxRetryDelay<0 {
+        return nil, fmt.Errorf("invalid negative option")
+    }
+    if options.MaxBatchMessages==0 {
+        options.MaxBatchMessages= defaultHTTPMaxBatchMessages
+    }
+    if options.MaxBatchSize==0 {
+        options.MaxBatchSize= defaultHTTPMaxBatchSize
+    }
+    if options.MaxBatchLatency==0 {
+        options.MaxBatchLatency= defaultHTTPMaxBatchLatency
+    }
+    if options.QueueCapacity==0 {
+        options.QueueCapacity= defaultHTTPQueueCapacity
+    }
+    if options.Client==nil {
+        options.Client= &http.Client{ Timeout: defaultHTTPTimeout}
+    }
+    if options.MaxRetries==0 {
+        options.MaxRetries= defaultHTTPMaxRetries
+    }
+    if options.MinRetryDelay==0 {
+        options.MinRetryDelay= defaultHTTPMinRetryDelay
+    }
+    if options.MaxRetryDelay==0 {
+        options.MaxRetryDelay= defaultHTTPMaxRetryDelay
+    }
+    if options.MaxRetryDelay<options.MinRetryDelay {
+        options.MaxRetryDelay= options.MinRetryDelay
+    }
+    if options.MaxSpillSize==0 {
+        options.MaxSpillSize= defaultMaxSpillSize
+    }
+
+    result := httpLogMessageSink{ BaseLogMessageSink: NewBaseLogMessageSink( threshold, false),
+                                  url: url,
+                                  options: options,
+                                  chStrLog: make( chan string, options.QueueCapacity),
+                                  chReqFlush: make( chan chan error),
+                                  chReqTerminate: make( chan struct{}),
+                                  chReplyTerminate: make( chan struct{}), }
+    if options.FailurePolicy==SpillHTTPFailurePolicy {
+        spillFile, err := os.OpenFile( options.SpillFilename, os.O_RDWR|os.O_CREATE, 0666)
+        if err!=nil {
+            return nil, fmt.Errorf("failed while trying to open the spill file %s:%s",
+                                   options.SpillFilename, err)
+        }
+        spillSize, err := spillFile.Seek( 0, io.SeekEnd)
+        if err!=nil {
+            spillFile.Close()
+            return nil, fmt.Errorf("failed while trying to seek the spill file %s:%s",
+                                   options.SpillFilename, err)
+        }
+        result.spillFile= spillFile
+        result.spillSize= Bytes(spillSize)
+    }
+    go httpSinkHandler( &result)
+    return &result, nil
+}
+
+//--------------------------------------------------------------------------------------------------
+// The messages are always JSON encoded, regardless of the encoding set for their type.
+func (h *httpLogMessageSink) OnLogMessage( msg *LogMessage) {
+    if msg.severity.IsGreaterOrEqualThan( h.threshold) {
+        h.chStrLog <- strings.TrimSuffix( encodeJSONLogMessage( msg), "\n")
+    }
+}
+
+//--------------------------------------------------------------------------------------------------
+// Same as FlushError(), the error is discarded.
+func (h *httpLogMessageSink) Flush() {
+    h.FlushError()
+}
+
+//--------------------------------------------------------------------------------------------------
+/* Waits until the queued messages are posted.  The result is the error of the last post, if the
+   messages could not be delivered. */
+func (h *httpLogMessageSink) FlushError() error {
+    chResult := make( chan error, 1)
+    h.chReqFlush <- chResult
+    return <- chResult
+}
+
+//--------------------------------------------------------------------------------------------------
+func (h *httpLogMessageSink) Terminate() {
+    close( h.chReqTerminate)
+    <- h.chReplyTerminate
+}
+
+//--------------------------------------------------------------------------------------------------
+func httpSinkHandler( ctx *httpLogMessageSink) {
+    for terminate:= false; !terminate; {
+        var chBatchTimer <-chan time.Time
+        if ctx.batchTimer!=nil {
+            chBatchTimer= ctx.batchTimer.C
+        }
+        select {
+            case strLog := <- ctx.chStrLog:
+                httpSinkOnNewStrLog( ctx, strLog)
+            case <- chBatchTimer:
+                ctx.batchTimer= nil
+                httpSinkSendBatch( ctx)
+            case chResult := <- ctx.chReqFlush:
+                chResult <- httpSinkWritePending( ctx)
+            case <- ctx.chReqTerminate:
+                httpSinkWritePending( ctx)
+                if ctx.spillFile!=nil {
+                    if err := ctx.spillFile.Close(); err!=nil {
+                        ctx.ReportError( fmt.Errorf("failed while trying to close the spill file %s:%w",
+                                                    ctx.options.SpillFilename, err))
+                    }
+                }
+                terminate= true
+        }
+    }
+    close( ctx.chReplyTerminate)
+}
+
+//--------------------------------------------------------------------------------------------------
+// Posts the messages waiting in the channel and the current batch.
+func httpSinkWritePending( ctx *httpLogMessageSink) error {
+    var result error
+    for hasLogStrings:= true; hasLogStrings; {
+        select {
+            case strLog := <- ctx.chStrLog:
+                if err := httpSinkOnNewStrLog( ctx, strLog); err!=nil {
+                    result= err
+                }
+            default:
+                hasLogStrings = false
+        }
+    }
+    if err := httpSinkSendBatch( ctx); err!=nil {
+        result= err
+    }
+    return result
+}
+
+//--------------------------------------------------------------------------------------------------
+// Adds the message to the batch, posting the batch when it is full.
+func httpSinkOnNewStrLog( ctx *httpLogMessageSink, strMessage string) error {
+    var result error
+    messageSize := Bytes( len(strMessage)+ 1)
+    if len(ctx.batch)>0 && ctx.batchSize+messageSize > Bytes(ctx.options.MaxBatchSize)*kBytesToBytes {
+        result= httpSinkSendBatch( ctx)
+    }
+    ctx.batch= append( ctx.batch, strMessage)
+    ctx.batchSize+= messageSize
+    if len(ctx.batch)>=ctx.options.MaxBatchMessages {
+        if err := httpSinkSendBatch( ctx); err!=nil {
+            result= err
+        }
+    } else if ctx.batchTimer==nil {
+        ctx.batchTimer= time.NewTimer( ctx.options.MaxBatchLatency)
+    }
+    return result
+}
+
+//--------------------------------------------------------------------------------------------------
+/* Posts the current batch, after the spilled messages.  If it cannot be delivered, the batch is
+   handled according to the failure policy and the error is reported. */
+func httpSinkSendBatch( ctx *httpLogMessageSink) error {
+    if ctx.batchTimer!=nil {
+        ctx.batchTimer.Stop()
+        ctx.batchTimer= nil
+    }
+    if len(ctx.batch)==0 {
+        err := httpSinkReplay( ctx)
+        if err!=nil {
+            ctx.ReportError( err)
+        }
+        return err
+    }
+    batch := ctx.batch
+    ctx.batch= nil
+    ctx.batchSize= 0
+
+    err := httpSinkReplay( ctx)
+    isRetryable := true
+    if err==nil {
+        isRetryable, err= httpSinkPost( ctx, batch)
+    }
+    if err!=nil {
+        if !isRetryable {
+            err= fmt.Errorf("%d messages dropped:%w", len(batch), err)
+        }
+        ctx.ReportError( err)
+        if isRetryable && ctx.options.FailurePolicy==SpillHTTPFailurePolicy {
+            httpSinkSpill( ctx, batch)
+        }
+    }
+    return err
+}
+
+//--------------------------------------------------------------------------------------------------
+/* Posts the messages, trying again with a backoff when the endpoint is not available.  The boolean
+   is true if they could not be delivered because the endpoint is not available, so that posting
+   them later can succeed. */
+func httpSinkPost( ctx *httpLogMessageSink, messages []string) (bool, error) {
+    if time.Now().Before( ctx.pauseUntil) {
+        return true, fmt.Errorf("the endpoint %s is not available", ctx.url)
+    }
+    body := httpSinkBody( messages, ctx.options.Format)
+    delay := ctx.options.MinRetryDelay
+    var err error
+    for attempt:=0; ; attempt++ {
+        var retry bool
+        if retry, err = httpSinkTryPost( ctx, body); err==nil || !retry {
+            return false, err
+        }
+        if attempt>=ctx.options.MaxRetries {
+            break
+        }
+        select {
+            case <- time.After( delay):
+            case <- ctx.chReqTerminate:
+                // Terminating: the batch is handled by the failure policy at once.
+                ctx.pauseUntil= time.Now().Add( ctx.options.MaxRetryDelay)
+                return true, err
+        }
+        delay*= 2
+        if delay>ctx.options.MaxRetryDelay {
+            delay= ctx.options.MaxRetryDelay
+        }
+    }
+    ctx.pauseUntil= time.Now().Add( ctx.options.MaxRetryDelay)
+    return true, err
+}
+
+/* Posts the body once.  The boolean is true if the request failed because the endpoint is not
+   available, and can be tried again. */
+func httpSinkTryPost( ctx *httpLogMessageSink, body []byte) (bool, error) {
+    request, err := http.NewRequest( http.MethodPost, ctx.url, bytes.NewReader( body))
+    if err!=nil {
+        return false, fmt.Errorf("failed while trying to create the request to %s:%s", ctx.url, err)
+    }
+    for key, values := range ctx.options.Headers {
+        for _, value := range values {
+            request.Header.Add( key, value)
+        }
+    }
+    if ctx.options.Format==JSONArrayHTTPBatchFormat {
+        request.Header.Set("Content-Type", "application/json")
+    } else {
+        request.Header.Set("Content-Type", "application/x-ndjson")
+    }
+    response, err := ctx.options.Client.Do( request)
+    if err!=nil {
+        return true, fmt.Errorf("failed while trying to post to %s:%s", ctx.url, err)
+    }
+    io.Copy( ioutil.Discard, response.Body)
+    response.Body.Close()
+    if response.StatusCode>=500 {
+        return true, fmt.Errorf("failed while trying to post to %s: status %s", ctx.url, response.Status)
+    }
+    if response.StatusCode>=300 {
+        return false, fmt.Errorf("failed while trying to post to %s: status %s", ctx.url, response.Status)
+    }
+    return false, nil
+}
+
+// Builds the body of the request.
+func httpSinkBody( messages []string, format HTTPBatchFormat) []byte {
+    var body bytes.Buffer
+    if format==JSONArrayHTTPBatchFormat {
+        body.WriteString("[")
+        body.WriteString( strings.Join( messages, ","))
+        body.WriteString("]")
+    } else {
+        for _, message := range messages {
+            body.WriteString( message)
+            body.WriteString("\n")
+        }
+    }
+    return body.Bytes()
+}
+
+//--------------------------------------------------------------------------------------------------
+// Appends the messages to the spill file, one per line.  If the file is full, they are dropped.
+func httpSinkSpill( ctx *httpLogMessageSink, messages []string) {
+    for indx, message := range messages {
+        lineSize := Bytes( len(message)+ 1)
+        if ctx.spillSize+lineSize > Bytes(ctx.options.MaxSpillSize)*kBytesToBytes {
+            ctx.ReportError( fmt.Errorf("the spill file %s is full, %d messages are dropped",
+                                        ctx.options.SpillFilename, len(messages)-indx))
+            return
+        }
+        if _, err := ctx.spillFile.WriteAt( []byte(message+"\n"), int64(ctx.spillSize)); err!=nil {
+            ctx.ReportError( fmt.Errorf("failed while trying to write to the spill file %s:%w",
+                                        ctx.options.SpillFilename, err))
+            return
+        }
+        ctx.spillSize+= lineSize
+    }
+}
+
+//--------------------------------------------------------------------------------------------------
+/* Posts the messages stored into the spill file, in batches, then empties it.  If the endpoint is
+   not available, the messages not posted yet are kept; a batch refused by the endpoint is dropped
+   and reported. */
+func httpSinkReplay( ctx *httpLogMessageSink) error {
+    if ctx.spillFile==nil || ctx.spillSize==0 {
+        return nil
+    }
+    if _, err := ctx.spillFile.Seek( 0, io.SeekStart); err!=nil {
+        return err
+    }
+    var messages []string
+    scanner := bufio.NewScanner( io.LimitReader( ctx.spillFile, int64(ctx.spillSize)))
+    scanner.Buffer( nil, int(ctx.options.MaxSpillSize)*int(kBytesToBytes))
+    for scanner.Scan() {
+        if len(scanner.Text())>0 {
+            messages= append( messages, scanner.Text())
+        }
+    }
+    if err := scanner.Err(); err!=nil {
+        return err
+    }
+
+    var err error
+    first := 0
+    for first<len(messages) {
+        last := first
+        var batchSize Bytes
+        for last<len(messages) && last-first<ctx.options.MaxBatchMessages {
+            messageSize := Bytes( len(messages[last])+ 1)
+            if last>first && batchSize+messageSize > Bytes(ctx.options.MaxBatchSize)*kBytesToBytes {
+                break
+            }
+            batchSize+= messageSize
+            last++
+        }
+        var isRetryable bool
+        if isRetryable, err = httpSinkPost( ctx, messages[first:last]); err!=nil {
+            if isRetryable {
+                break
+            }
+            ctx.ReportError( fmt.Errorf("%d spilled messages dropped:%w", last-first, err))
+            err= nil
+        }
+        first= last
+    }
+
+    var remaining bytes.Buffer
+    for _, message := range messages[first:] {
+        remaining.WriteString( message)
+        remaining.WriteString("\n")
+    }
+    if remaining.Len()>0 {
+        if _, errWrite := ctx.spillFile.WriteAt( remaining.Bytes(), 0); errWrite!=nil {
+            return errWrite
+        }
+    }
+    if errTruncate := ctx.spillFile.Truncate( int64(remaining.Len())); errTruncate!=nil {
+        return errTruncate
+    }
+    ctx.spillSize= Bytes( remaining.Len())
+    return err
+}
@@ -0,0 +1,279 @@
+package dmlog
+
+import "encoding/json"
+import "io/ioutil"
+import "net/http"
+import "net/http/httptest"
+import "os"
+import "path/filepath"
+import "strings"
+import "sync"
+import "testing"
+import "time"
+
+// Records the bodies of the requests, replying with the configured status.
+type recordingHTTPHandler struct {
+    mtx        sync.Mutex
+    status     int
+    numCalls   int
+    bodies     []string
+    headers    []http.Header
+    chRequest  chan struct{}
+}
+
+func newRecordingHTTPHandler() *recordingHTTPHandler {
+    return &recordingHTTPHandler{ status: http.StatusOK, chRequest: make( chan struct{}, 100)}
+}
+
+func (h *recordingHTTPHandler) ServeHTTP( w http.ResponseWriter, r *http.Request) {
+    body, _ := ioutil.ReadAll( r.Body)
+    h.mtx.Lock()
+    h.numCalls++
+    status := h.status
+    if status==http.StatusOK {
+        h.bodies= append( h.bodies, string(body))
+        h.headers= append( h.headers, r.Header)
+    }
+    h.mtx.Unlock()
+    w.WriteHeader( status)
+    h.chRequest <- struct{}{}
+}
+
+func (h *recordingHTTPHandler) setStatus( status int) {
+    h.mtx.Lock()
+    defer h.mtx.Unlock()
+    h.status= status
+}
+
+func (h *recordingHTTPHandler) results() (int, []string, []http.Header) {
+    h.mtx.Lock()
+    defer h.mtx.Unlock()
+    return h.numCalls, append( []string{}, h.bodies...), append( []http.Header{}, h.headers...)
+}
+
+//--------------------------------------------------------------------------------------------------
+func TestHTTPSinkBatches( t *testing.T) {
+    handler := newRecordingHTTPHandler()
+    server := httptest.NewServer( handler)
+    defer server.Close()
+
+    logger := NewLogger()
+    defer logger.Terminate()
+    headers := http.Header{}
+    headers.Set("Authorization", "Bearer token")
+    _, err := logger.AddHTTPSinkWithOptions( server.URL, InfoSeverity,
+                                             HTTPSinkOptions{ Format: JSONArrayHTTPBatchFormat,
+                                                              Headers: headers,
+                                                              MaxBatchMessages: 2, })
+    if err!=nil {
+        t.Error(t.Name(),`AddHTTPSinkWithOptions() failed:`,err)
+        return
+    }
+    logger.Debug("Debug message")
+    logger.Info("First message")
+    logger.Info("Second message")
+    logger.Info("Third message")
+    if err := logger.Flush(); err!=nil {
+        t.Error(t.Name(),`Flush() failed:`,err)
+    }
+
+    _, bodies, requestHeaders := handler.results()
+    if len(bodies)!=2 {
+        t.Error(t.Name(),`Unexpected number of requests:`,len(bodies))
+        return
+    }
+    expected := [][]string{ {"First message", "Second message"}, {"Third message"}}
+    for indx, body := range bodies {
+        var messages []map[string]interface{}
+        if err := json.Unmarshal( []byte(body), &messages); err!=nil {
+            t.Error(t.Name(),`Unmarshal() failed:`,err,body)
+            continue
+        }
+        if len(messages)!=len(expected[indx]) {
+            t.Error(t.Name(),`Unexpected batch:`,body)
+            continue
+        }
+        for msgIndx, message := range messages {
+            if message["text"]!=expected[indx][msgIndx] {
+                t.Error(t.Name(),`Unexpected message:`,message["text"],`expected:`,expected[indx][msgIndx])
+            }
+        }
+        if requestHeaders[indx].Get("Authorization")!="Bearer token" ||
+           requestHeaders[indx].Get("Content-Type")!="application/json" {
+            t.Error(t.Name(),`Unexpected headers:`,requestHeaders[indx])
+        }
+    }
+}
+
+//--------------------------------------------------------------------------------------------------
+func TestHTTPSinkLatency( t *testing.T) {
+    handler := newRecordingHTTPHandler()
+    server := httptest.NewServer( handler)
+    defer server.Close()
+
+    logger := NewLogger()
+    defer logger.Terminate()
+    _, err := logger.AddHTTPSinkWithOptions( server.URL, InfoSeverity,
+                                             HTTPSinkOptions{ MaxBatchLatency: 10*time.Millisecond})
+    if err!=nil {
+        t.Error(t.Name(),`AddHTTPSinkWithOptions() failed:`,err)
+        return
+    }
+    logger.Info("Info message")
+    select {
+        case <- handler.chRequest:
+        case <- time.After( 5*time.Second):
+            t.Error(t.Name(),`The batch was not posted`)
+            return
+    }
+    _, bodies, _ := handler.results()
+    if len(bodies)!=1 || !strings.HasSuffix( bodies[0], `"text":"Info message"}`+"\n") {
+        t.Error(t.Name(),`Unexpected requests:`,bodies)
+    }
+}
+
+//--------------------------------------------------------------------------------------------------
+func TestHTTPSinkRetry( t *testing.T) {
+    handler := newRecordingHTTPHandler()
+    handler.setStatus( http.StatusServiceUnavailable)
+    server := httptest.NewServer( handler)
+    defer server.Close()
+
+    logger := NewLogger()
+    defer logger.Terminate()
+    _, err := logger.AddHTTPSinkWithOptions( server.URL, InfoSeverity,
+                                             HTTPSinkOptions{ MaxRetries: 5,
+                                                              MinRetryDelay: time.Millisecond,
+                                                              MaxRetryDelay: time.Millisecond, })
+    if err!=nil {
+        t.Error(t.Name(),`AddHTTPSinkWithOptions() failed:`,err)
+        return
+    }
+    go func() {
+        <- handler.chRequest
+        <- handler.chRequest
+        handler.setStatus( http.StatusOK)
+    }()
+    logger.Info("Info message")
+    if err := logger.Flush(); err!=nil {
+        t.Error(t.Name(),`Flush() failed:`,err)
+    }
+    numCalls, bodies, _ := handler.results()
+    if numCalls<3 || len(bodies)!=1 {
+        t.Error(t.Name(),`Unexpected requests:`,numCalls,bodies)
+    }
+}
+
+//--------------------------------------------------------------------------------------------------
+func TestHTTPSinkSpill( t *testing.T) {
+    dirPath, err := ioutil.TempDir("", "log_http_sink_test_")
+    if err!=nil {
+        t.Error(t.Name(),`TempDir() failed:`,err)
+        return
+    }
+    defer os.RemoveAll( dirPath)
+
+    handler := newRecordingHTTPHandler()
+    handler.setStatus( http.StatusInternalServerError)
+    server := httptest.NewServer( handler)
+    defer server.Close()
+
+    spillFilename := filepath.Join( dirPath, "spill")
+    logger := NewLogger()
+    defer logger.Terminate()
+    _, err = logger.AddHTTPSinkWithOptions( server.URL, InfoSeverity,
+                                            HTTPSinkOptions{ MaxRetries: -1,
+                                                             MinRetryDelay: time.Millisecond,
+                                                             MaxRetryDelay: time.Millisecond,
+                                                             FailurePolicy: SpillHTTPFailurePolicy,
+                                                             SpillFilename: spillFilename, })
+    if err!=nil {
+        t.Error(t.Name(),`AddHTTPSinkWithOptions() failed:`,err)
+        return
+    }
+    logger.Info("First message")
+    if err := logger.Flush(); err==nil {
+        t.Error(t.Name(),`Flush() did not fail`)
+    }
+    logger.Info("Second message")
+    logger.Flush()
+    if fileInfo, err := os.Stat( spillFilename); err!=nil || fileInfo.Size()==0 {
+        t.Error(t.Name(),`Unexpected spill file:`,fileInfo,err)
+    }
+
+    handler.setStatus( http.StatusOK)
+    time.Sleep( 2*time.Millisecond)
+    logger.Info("Third message")
+    if err := logger.Flush(); err!=nil {
+        t.Error(t.Name(),`Flush() failed:`,err)
+    }
+    _, bodies, _ := handler.results()
+    var texts []string
+    for _, body := range bodies {
+        for _, line := range strings.Split( strings.TrimSpace( body), "\n") {
+            var message map[string]interface{}
+            if err := json.Unmarshal( []byte(line), &message); err!=nil {
+                t.Error(t.Name(),`Unmarshal() failed:`,err,line)
+                continue
+            }
+            texts= append( texts, message["text"].(string))
+        }
+    }
+    expected := "First message,Second message,Third message"
+    if strings.Join( texts, ",")!=expected {
+        t.Error(t.Name(),`Unexpected messages: got:`,texts,`expected:`,expected)
+    }
+    if fileInfo, err := os.Stat( spillFilename); err!=nil || fileInfo.Size()!=0 {
+        t.Error(t.Name(),`The spill file is not empty:`,fileInfo,err)
+    }
+}
+
+//--------------------------------------------------------------------------------------------------
+func TestHTTPSinkRefusedBatch( t *testing.T) {
+    dirPath, err := ioutil.TempDir("", "log_http_sink_test_")
+    if err!=nil {
+        t.Error(t.Name(),`TempDir() failed:`,err)
+        return
+    }
+    defer os.RemoveAll( dirPath)
+
+    handler := newRecordingHTTPHandler()
+    handler.setStatus( http.StatusBadRequest)
+    server := httptest.NewServer( handler)
+    defer server.Close()
+
+    spillFilename := filepath.Join( dirPath, "spill")
+    logger := NewLogger()
+    defer logger.Terminate()
+    sinkId, err := logger.AddHTTPSinkWithOptions( server.URL, InfoSeverity,
+                                                  HTTPSinkOptions{ MaxRetries: 5,
+                                                                   MinRetryDelay: time.Millisecond,
+                                                                   MaxRetryDelay: time.Millisecond,
+                                                                   FailurePolicy: SpillHTTPFailurePolicy,
+                                                                   SpillFilename: spillFilename, })
+    if err!=nil {
+        t.Error(t.Name(),`AddHTTPSinkWithOptions() failed:`,err)
+        return
+    }
+    logger.Info("Refused message")
+    if err := logger.Flush(); err==nil {
+        t.Error(t.Name(),`Flush() did not fail`)
+    }
+    if fileInfo, err := os.Stat( spillFilename); err!=nil || fileInfo.Size()!=0 {
+        t.Error(t.Name(),`The refused batch was spilled:`,fileInfo,err)
+    }
+
+    handler.setStatus( http.StatusOK)
+    logger.Info("Accepted message")
+    if err := logger.Flush(); err!=nil {
+        t.Error(t.Name(),`Flush() failed:`,err)
+    }
+    numCalls, bodies, _ := handler.results()
+    if numCalls!=2 || len(bodies)!=1 || !strings.Contains( bodies[0], `"text":"Accepted message"`) {
+        t.Error(t.Name(),`Unexpected requests:`,numCalls,bodies)
+    }
+    stats, _ := logger.SinkStatistics( sinkId)
+    if stats.Errors!=1 || !strings.Contains( stats.LastError.Error(), "1 messages dropped") {
+        t.Error(t.Name(),`Unexpected errors:`,stats.Errors,stats.LastError)
+    }
+}
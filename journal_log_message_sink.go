@@ -0,0 +1,179 @@
+package dmlog
+
+import "bytes"
+import "encoding/binary"
+import "fmt"
+import "net"
+import "strconv"
+import "strings"
+
+// The socket of systemd-journald receiving messages by the native protocol.
+const defaultJournalAddress string = "/run/systemd/journal/socket"
+
+// The journal fields written by the sink: structured fields with these names are renamed.
+var reservedJournalFields = map[string]bool{ "MESSAGE": true, "PRIORITY": true,
+                                             "SYSLOG_IDENTIFIER": true, "CODE_FILE": true,
+                                             "CODE_LINE": true, "CODE_FUNC": true, }
+
+// Implementation of a log sink that sends messages to systemd-journald.
+type journalLogMessageSink struct {
+    BaseLogMessageSink
+    address   string
+    appName   string
+    conn      net.Conn
+}
+
+/* Adds a log message sink that sends messages to systemd-journald, by its native protocol.
+   The journal entries have the MESSAGE, PRIORITY, SYSLOG_IDENTIFIER, CODE_FILE, CODE_LINE and
+   CODE_FUNC fields, plus the structured fields of the message, whose keys are converted to upper
+   case and where any character but letters, digits and '_' is replaced by '_'.  The keys 
+   colliding with the fields written by the sink, e.g. "message", get the FIELD_ prefix.
+   appName is the SYSLOG_IDENTIFIER; when empty the field is not sent.
+   In case error is nil, the returned message sink id can be used later to modify the severity
+   threshold.*/
+func AddJournalSink( appName string, threshold LogSeverity) (MessageSinkId, error) {
+    return DefaultLogger().AddJournalSinkWithAddress( defaultJournalAddress, appName, threshold)
+}
+
+// Same as AddJournalSink(), sending to the unixgram socket at address.
+func AddJournalSinkWithAddress( address string,
+                                appName string,
+                                threshold LogSeverity) (MessageSinkId, error) {
+    return DefaultLogger().AddJournalSinkWithAddress( address, appName, threshold)
+}
+
+// Adds to the logger a sink that sends messages to systemd-journald, see AddJournalSink().
+func (l *Logger) AddJournalSink( appName string, threshold LogSeverity) (MessageSinkId, error) {
+    return l.AddJournalSinkWithAddress( defaultJournalAddress, appName, threshold)
+}
+
+// Adds to the logger a journal sink sending to the given address, see AddJournalSinkWithAddress().
+func (l *Logger) AddJournalSinkWithAddress( address string,
+                                            appName string,
+                                            threshold LogSeverity) (MessageSinkId, error) {
+    msgSink, err := newJournalLogMessageSink( address, appName, threshold)
+    if err!=nil {
+        return MessageSinkId(0), err
+    }
+    return l.addMessageSink( msgSink)
+}
+
+//--------------------------------------------------------------------------------------------------
+func newJournalLogMessageSink( address string,
+                               appName string,
+                               threshold LogSeverity) (*journalLogMessageSink, error) {
+    obj := journalLogMessageSink{ BaseLogMessageSink: NewBaseLogMessageSink( threshold, false),
+                                  address: address,
+                                  appName: appName, }
+    if err := obj.connect(); err!=nil {
+        return nil, err
+    }
+    return &obj, nil
+}
+
+//--------------------------------------------------------------------------------------------------
+func (j *journalLogMessageSink) OnLogMessage( msg *LogMessage) {
+    if ! msg.severity.IsGreaterOrEqualThan( j.threshold) {
+        return
+    }
+    if err := j.send( j.encode( msg)); err!=nil {
+        j.ReportError( err)
+    }
+}
+
+//--------------------------------------------------------------------------------------------------
+func (j *journalLogMessageSink) Flush() {
+}
+
+//--------------------------------------------------------------------------------------------------
+func (j *journalLogMessageSink) Terminate() {
+    if j.conn!=nil {
+        j.conn.Close()
+        j.conn= nil
+    }
+}
+
+//--------------------------------------------------------------------------------------------------
+func (j *journalLogMessageSink) connect() error {
+    conn, err := net.Dial("unixgram", j.address)
+    if err!=nil {
+        return fmt.Errorf("failed while trying to connect to the journal %s:%s", j.address, err)
+    }
+    j.conn= conn
+    return nil
+}
+
+//--------------------------------------------------------------------------------------------------
+// Sends the datagram, connecting again once if it fails, e.g. after journald was restarted.
+func (j *journalLogMessageSink) send( datagram []byte) error {
+    var err error
+    for attempt:=0; attempt<2; attempt++ {
+        if j.conn==nil {
+            if err = j.connect(); err!=nil {
+                return err
+            }
+        }
+        if _, err = j.conn.Write( datagram); err==nil {
+            return nil
+        }
+        err= fmt.Errorf("failed while trying to send to the journal %s:%w", j.address, err)
+        j.conn.Close()
+        j.conn= nil
+    }
+    return err
+}
+
+//--------------------------------------------------------------------------------------------------
+// Encodes the message as a datagram of the journal native protocol.
+func (j *journalLogMessageSink) encode( msg *LogMessage) []byte {
+    var datagram bytes.Buffer
+    writeJournalField( &datagram, "MESSAGE", msg.text)
+    writeJournalField( &datagram, "PRIORITY", strconv.Itoa( syslogSeverity( msg.severity)))
+    if len(j.appName)>0 {
+        writeJournalField( &datagram, "SYSLOG_IDENTIFIER", j.appName)
+    }
+    writeJournalField( &datagram, "CODE_FILE", msg.filename)
+    writeJournalField( &datagram, "CODE_LINE", strconv.Itoa( msg.line))
+    writeJournalField( &datagram, "CODE_FUNC", msg.funcName)
+    for _, field := range msg.fields {
+        writeJournalField( &datagram, journalFieldName( field.Key), fmt.Sprint( field.Value))
+    }
+    return datagram.Bytes()
+}
+
+/* Writes a field as NAME=value followed by a new line.  A value with new lines is written as the
+   name, a new line, the value length as 64 bits little endian, the value and a new line. */
+func writeJournalField( datagram *bytes.Buffer, name string, value string) {
+    datagram.WriteString( name)
+    if strings.IndexByte( value, '\n')<0 {
+        datagram.WriteString("=")
+        datagram.WriteString( value)
+    } else {
+        datagram.WriteString("\n")
+        binary.Write( datagram, binary.LittleEndian, uint64( len(value)))
+        datagram.WriteString( value)
+    }
+    datagram.WriteString("\n")
+}
+
+/* Retrieves a valid journal field name: upper case letters, digits and '_', not starting with
+   '_' nor a digit, at most 64 characters.  Names reserved by the sink get the FIELD_ prefix. */
+func journalFieldName( key string) string {
+    name := strings.Map( func(r rune) rune {
+                             switch {
+                                 case r>='A' && r<='Z', r>='0' && r<='9', r=='_':
+                                     return r
+                                 case r>='a' && r<='z':
+                                     return r-'a'+'A'
+                             }
+                             return '_'
+                         }, key)
+    name= strings.TrimLeft( name, "_")
+    if len(name)==0 || (name[0]>='0' && name[0]<='9') || reservedJournalFields[name] {
+        name= "FIELD_"+ name
+    }
+    if len(name)>64 {
+        name= name[:64]
+    }
+    return name
+}
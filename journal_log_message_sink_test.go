@@ -0,0 +1,69 @@
+package dmlog
+
+import "encoding/binary"
+import "io/ioutil"
+import "net"
+import "os"
+import "path/filepath"
+import "testing"
+import "time"
+
+//--------------------------------------------------------------------------------------------------
+func TestJournalFieldName( t *testing.T) {
+    expected := map[string]string{ "user":"USER", "request-id":"REQUEST_ID", "_secret":"SECRET",
+                                   "1st":"FIELD_1ST", "":"FIELD_", "message":"FIELD_MESSAGE",
+                                   "priority":"FIELD_PRIORITY", "code_line":"FIELD_CODE_LINE", }
+    for key, name := range expected {
+        if got := journalFieldName( key); got!=name {
+            t.Error(t.Name(),`Unexpected name for`,key,`got:`,got,`expected:`,name)
+        }
+    }
+}
+
+//--------------------------------------------------------------------------------------------------
+func TestJournalSink( t *testing.T) {
+    dirPath, err := ioutil.TempDir("", "log_journal_sink_test_")
+    if err!=nil {
+        t.Error(t.Name(),`TempDir() failed:`,err)
+        return
+    }
+    defer os.RemoveAll( dirPath)
+
+    address := filepath.Join( dirPath, "socket")
+    conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{ Name: address, Net: "unixgram"})
+    if err!=nil {
+        t.Skip(t.Name(),`unixgram sockets are not supported:`,err)
+    }
+    defer conn.Close()
+
+    logger := NewLogger()
+    defer logger.Terminate()
+    _, err = logger.AddJournalSinkWithAddress( address, "test", InfoSeverity)
+    if err!=nil {
+        t.Error(t.Name(),`AddJournalSinkWithAddress() failed:`,err)
+        return
+    }
+    logger.Debug("Debug message")
+    logger.WarnKV("Multi\nline", "user", 42, "priority", "high")
+    logger.Flush()
+
+    conn.SetReadDeadline( time.Now().Add( 5*time.Second))
+    buffer := make([]byte, 4096)
+    n, err := conn.Read( buffer)
+    if err!=nil {
+        t.Error(t.Name(),`Read() failed:`,err)
+        return
+    }
+    datagram := string(buffer[:n])
+    length := make([]byte, 8)
+    binary.LittleEndian.PutUint64( length, uint64( len("Multi\nline")))
+    expectedStart := "MESSAGE\n"+ string(length)+ "Multi\nline\nPRIORITY=4\nSYSLOG_IDENTIFIER=test\n"+
+                     "CODE_FILE="
+    if len(datagram)<len(expectedStart) || datagram[:len(expectedStart)]!=expectedStart {
+        t.Errorf("%s Unexpected datagram: %q", t.Name(), datagram)
+    }
+    expectedEnd := "\nUSER=42\nFIELD_PRIORITY=high\n"
+    if len(datagram)<len(expectedEnd) || datagram[len(datagram)-len(expectedEnd):]!=expectedEnd {
+        t.Errorf("%s Unexpected datagram: %q", t.Name(), datagram)
+    }
+}
@@ -0,0 +1,66 @@
+package dmlog
+
+/* The lifecycle state of a logger:
+   - RunningLoggerState: the logger accepts messages and requests; it is the state of a new logger.
+   - DrainingLoggerState: Terminate() was called, the pending messages are being delivered to the
+     sinks, then the sinks are terminated.  New messages are discarded.
+   - TerminatedLoggerState: the sinks are terminated; the logger cannot be used any more.
+   The states are traversed in this order only.  The default logger, once terminated, is replaced
+   by a new running one by means of Init() or Reset(). */
+type LoggerState int
+
+const (
+    RunningLoggerState LoggerState = iota
+    DrainingLoggerState
+    TerminatedLoggerState
+)
+
+// Implements the Stringable interface
+func (s LoggerState) String() string {
+    switch s {
+        case RunningLoggerState:    return "running"
+        case DrainingLoggerState:   return "draining"
+        case TerminatedLoggerState: return "terminated"
+    }
+    return "Unknown"
+}
+
+/* Starts the default logger again after Terminate(): it is replaced by a new logger, with no sinks,
+   the debug severity threshold and the default settings.  
+   It has no effect while the default logger is running. */
+func Init() {
+    mtxDefaultLogger.Lock()
+    defer mtxDefaultLogger.Unlock()
+    if defaultLogger.IsTerminated() {
+        defaultLogger= NewLogger()
+    }
+}
+
+/* Replaces the default logger by a new logger, with no sinks, the debug severity threshold and the
+   default settings, then terminates the previous one: its pending messages are delivered to its
+   sinks, then the sinks are terminated.  It gives each test a fresh setup. */
+func Reset() {
+    mtxDefaultLogger.Lock()
+    previous := defaultLogger
+    defaultLogger= NewLogger()
+    mtxDefaultLogger.Unlock()
+    previous.Terminate()
+}
+
+// Retrieves the lifecycle state of the default logger.
+func State() LoggerState {
+    return DefaultLogger().State()
+}
+
+// Retrieves the lifecycle state of the logger, see LoggerState.
+func (l *Logger) State() LoggerState {
+    select {
+        case <- l.chReplyTerminate:
+            return TerminatedLoggerState
+        default:
+    }
+    if l.IsTerminated() {
+        return DrainingLoggerState
+    }
+    return RunningLoggerState
+}
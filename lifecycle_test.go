@@ -0,0 +1,159 @@
+package dmlog
+
+import "io/ioutil"
+import "testing"
+import "time"
+
+//--------------------------------------------------------------------------------------------------
+func TestTerminated( t *testing.T) {    
+    defer Reset()
+    if IsTerminated() {
+        t.Error(t.Name(),`IsTerminated(): got true, expected false`)            
+    }
+    if State()!=RunningLoggerState {
+        t.Error(t.Name(),`State(): got`,State(),`expected:`,RunningLoggerState)
+    }
+    
+    Flush()
+    severity := Severity()
+    Terminate()
+    if ! IsTerminated() {
+        t.Error(t.Name(),`IsTerminated(): got false, expected true`)            
+    }    
+    if State()!=TerminatedLoggerState {
+        t.Error(t.Name(),`State(): got`,State(),`expected:`,TerminatedLoggerState)
+    }
+    // Terminating again has no effect, as setting the severity.
+    Terminate()
+    SetSeverity( WarningSeverity)
+    if Severity()!=severity {
+        t.Error(t.Name(),`Unexpected severity after Terminate():`,Severity())
+    }
+
+    Init()
+    if IsTerminated() || State()!=RunningLoggerState {
+        t.Error(t.Name(),`Init() did not start the default logger:`,State())
+    }
+    SetSeverity( InfoSeverity)
+    if Severity()!=InfoSeverity {
+        t.Error(t.Name(),`Unexpected severity after Init():`,Severity())
+    }
+    previous := DefaultLogger()
+    Init()
+    if DefaultLogger()!=previous {
+        t.Error(t.Name(),`Init() replaced a running default logger`)
+    }
+}
+
+//--------------------------------------------------------------------------------------------------
+func TestReset( t *testing.T) {
+    defer Reset()
+    sink := &recordingLogMessageSink{ BaseLogMessageSink: NewBaseLogMessageSink( DebugSeverity, false) }
+    if _, err := AddSink( sink); err!=nil {
+        t.Error(t.Name(),`AddSink() failed:`,err)
+        return
+    }
+    SetSeverity( WarningSeverity)
+    previous := DefaultLogger()
+    Warn("Warning message")
+    Reset()
+
+    if !sink.isTerminated || len(sink.texts)!=1 || sink.texts[0]!="Warning message" {
+        t.Error(t.Name(),`The previous sinks were not terminated:`,sink.isTerminated,sink.texts)
+    }
+    if previous.State()!=TerminatedLoggerState {
+        t.Error(t.Name(),`Unexpected state of the previous logger:`,previous.State())
+    }
+    if DefaultLogger()==previous || State()!=RunningLoggerState || Severity()!=DebugSeverity {
+        t.Error(t.Name(),`Reset() did not create a fresh default logger:`,State(),Severity())
+    }
+}
+
+//--------------------------------------------------------------------------------------------------
+func TestLoggerState( t *testing.T) {
+    logger := NewLogger()
+    sink := &blockingLogMessageSink{ BaseLogMessageSink: NewBaseLogMessageSink( DebugSeverity, false),
+                                     chRelease: make( chan struct{}), }
+    if _, err := logger.AddSink( sink); err!=nil {
+        t.Error(t.Name(),`AddSink() failed:`,err)
+        return
+    }
+    logger.Info("Info message")
+    chTerminated := make( chan struct{})
+    go func() {
+        logger.Terminate()
+        close( chTerminated)
+    }()
+    // The blocked sink keeps the logger draining.
+    for logger.State()==RunningLoggerState {
+        time.Sleep( time.Millisecond)
+    }
+    if logger.State()!=DrainingLoggerState {
+        t.Error(t.Name(),`Unexpected state:`,logger.State(),`expected:`,DrainingLoggerState)
+    }
+    close( sink.chRelease)
+    logger.Terminate()
+    <- chTerminated
+    if logger.State()!=TerminatedLoggerState {
+        t.Error(t.Name(),`Unexpected state:`,logger.State(),`expected:`,TerminatedLoggerState)
+    }
+}
+
+//--------------------------------------------------------------------------------------------------
+// Calls the methods issuing requests to the dispatcher: all of them must fail without blocking.
+func checkRequestsFail( t *testing.T, logger *Logger, sinkId MessageSinkId) {
+    chDone := make( chan struct{})
+    go func() {
+        defer close( chDone)
+        sink := &recordingLogMessageSink{ BaseLogMessageSink: NewBaseLogMessageSink( DebugSeverity, false) }
+        if _, err := logger.AddSink( sink); err==nil {
+            t.Error(t.Name(),`AddSink() succeeded`)
+        }
+        if _, err := logger.AddWriterSink( ioutil.Discard, DebugSeverity); err==nil {
+            t.Error(t.Name(),`AddWriterSink() succeeded`)
+        }
+        if logger.SetMessageSinkSeverity( sinkId, WarningSeverity) ||
+           logger.SetSinkOutputFormat( sinkId, LogMessageType, TextFmt) ||
+           logger.SetSinkOutputEncoding( sinkId, LogMessageType, JSONEncoding) ||
+           logger.SetFileSinkPathCheck( sinkId, time.Second) ||
+           logger.RemoveSink( sinkId) || logger.ClearSinks() {
+            t.Error(t.Name(),`A request on the sinks succeeded`)
+        }
+        if _, ok := logger.SinkStatistics( sinkId); ok {
+            t.Error(t.Name(),`SinkStatistics() succeeded`)
+        }
+        if err := logger.ReopenFileSinks(); err==nil {
+            t.Error(t.Name(),`ReopenFileSinks() succeeded`)
+        }
+    }()
+    select {
+        case <- chDone:
+        case <- time.After( 10*time.Second):
+            t.Error(t.Name(),`A request blocked on the terminated logger`)
+    }
+}
+
+//--------------------------------------------------------------------------------------------------
+func TestRequestsAfterTerminate( t *testing.T) {
+    logger := NewLogger()
+    sinkId, err := logger.AddWriterSink( ioutil.Discard, DebugSeverity)
+    if err!=nil {
+        t.Error(t.Name(),`AddWriterSink() failed:`,err)
+        return
+    }
+    logger.Terminate()
+    checkRequestsFail( t, logger, sinkId)
+
+    sinkId, err = AddWriterSink( ioutil.Discard, DebugSeverity)
+    if err!=nil {
+        t.Error(t.Name(),`AddWriterSink() failed:`,err)
+        return
+    }
+    previous := DefaultLogger()
+    Reset()
+    checkRequestsFail( t, previous, sinkId)
+    // The new default logger accepts the requests.
+    if SetMessageSinkSeverity( sinkId, WarningSeverity) || !ClearSinks() {
+        t.Error(t.Name(),`Unexpected sinks of the new default logger`)
+    }
+}
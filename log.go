@@ -1,7 +1,10 @@
 // Package implementing a logging facility.
 package dmlog
 
-import "log"
+import "context"
+import "errors"
+import "fmt"
+import "os"
 import "sync"
 import "time"
 
@@ -11,7 +14,8 @@ const defaultSkip int = 1
 
 const fatalLogTerminated string = "Log facility already terminated."
 
-// Unique identifier of a Message sing
+/* Unique identifier of a message sink, within its logger.
+   Identifiers are never reused, even after the sink is removed. */
 type MessageSinkId int
 
 // Each message type can be given a specific format.
@@ -38,6 +42,8 @@ const (
     FatalSeverity
 ) 
 
+/* A log message, as received by the message sinks.
+   The content is read only: it is accessed by means of the getter methods. */
 type LogMessage struct {
     text        string
     severity    LogSeverity
@@ -46,6 +52,59 @@ type LogMessage struct {
     filename    string
     line        int
     funcName    string
+    fields      []Field
+}
+
+/* Creates a log message with the given content.
+   It is meant for testing the custom sinks and filters, since the messages delivered to the sinks
+   are created by the logging functions. */
+func NewLogMessage( text        string,
+                    severity    LogSeverity,
+                    messageType MessageType,
+                    timestamp   time.Time,
+                    filename    string,
+                    line        int,
+                    funcName    string,
+                    fields      ...Field) *LogMessage {
+    return &LogMessage{ text: text,
+                        severity: severity,
+                        messageType: messageType,
+                        timestamp: timestamp,
+                        filename: filename,
+                        line: line,
+                        funcName: funcName,
+                        fields: fields, }
+}
+
+// Retrieves the text of the message.
+func (m *LogMessage) Text() string { return m.text }
+
+// Retrieves the severity of the message.
+func (m *LogMessage) Severity() LogSeverity { return m.severity }
+
+// Retrieves the type of the message.
+func (m *LogMessage) MessageType() MessageType { return m.messageType }
+
+// Retrieves the time when the message was issued.
+func (m *LogMessage) Timestamp() time.Time { return m.timestamp }
+
+// Retrieves the source file where the message was issued.
+func (m *LogMessage) Filename() string { return m.filename }
+
+// Retrieves the source line where the message was issued.
+func (m *LogMessage) Line() int { return m.line }
+
+// Retrieves the name of the function where the message was issued.
+func (m *LogMessage) FuncName() string { return m.funcName }
+
+// Retrieves a copy of the structured fields of the message, in the order they were given.
+func (m *LogMessage) Fields() []Field {
+    if len(m.fields)==0 {
+        return nil
+    }
+    result := make([]Field, len(m.fields))
+    copy( result, m.fields)
+    return result
 }
 
 //--------------------------------------------------------------------------------------------------
@@ -67,25 +126,61 @@ func (t LogSeverity) String() string {
     return "Unknown"
 }
 
+/* The contract of a message sink, i.e. the destination of the log messages.
+   Each sink runs in its own goroutine, behind a bounded queue, so that a slow sink does not stall
+   the others.  All the methods of a sink are called by that goroutine, therefore an implementation
+   does not need to protect its state against concurrent access.
+   Custom sinks can embed BaseLogMessageSink, that implements the threshold, flush and format
+   related methods, and then they are registered by means of AddSink(). */
 type LogMessageSink interface {
+    // Sets the severity threshold: messages below the threshold must be discarded.
     SetSeverity( threshold LogSeverity)
     
+    // Retrieves the severity threshold.
     Severity() LogSeverity    
     
+    // Called for each log message issued above the global severity threshold.
     OnLogMessage( msg *LogMessage)
     
-    flush()
+    // Writes any buffered data to the underlying destination.
+    Flush()
 
-    setSinkFormat( messageType MessageType, format LogFormatItems) bool
+    // Sets the format used for the given message type.  Returns true on success.
+    SetSinkFormat( messageType MessageType, format LogFormatItems) bool
     
+    // When isFrequentFlush is true, the sink is flushed after each message.
     SetFlush( isFrequentFlush bool)
     
     /* The message sink is terminated: it must release its resources. */
-    terminate()    
+    Terminate()    
+}
+
+/* Optionally implemented by a sink whose flush can fail: the logger calls FlushError() in place of
+   Flush(), and Logger.Flush() returns its error. */
+type ErrorFlusher interface {
+    /* Writes any buffered or queued data to the underlying destination, then returns.
+       Returns an error if the data could not be written. */
+    FlushError() error
+}
+
+/* Optionally implemented by a sink that supports several output encodings, see 
+   Logger.SetSinkOutputEncoding(). */
+type SinkEncodingSetter interface {
+    // Sets the encoding used for the given message type.  Returns true on success.
+    SetSinkEncoding( messageType MessageType, encoding OutputEncoding) bool
 }
 
 //--------------------------------------------------------------------------------------------------
-var context struct {
+/* An independent logging facility: it has its own message dispatcher, sinks, global severity 
+   threshold and lifecycle.
+   The package level functions (Debug(), AddConsoleSink(), Terminate(), ...) use the default 
+   logger, see DefaultLogger(). */
+type Logger struct {
+    /* The number of messages dropped by the backpressure policy or by the sinks, and the number 
+       already reported by Flush(), updated atomically; first, to be 64 bits aligned. */
+    droppedMessages        uint64
+    flushedDroppedMessages uint64
+
     /* The severity is atomic, it is checked before a message is sent. */
     severity LogSeverity
     
@@ -95,86 +190,262 @@ var context struct {
     /* The most important channel, where log messages are sent to the dispatcher.*/
     chLogMessages chan LogMessage
 
-    /* The channel to send requests to the message dispatcher and receive the corresponding reply.*/
-    chRequest chan interface{}
-
-    chReply chan interface{}
+    /* The channel to send requests to the message dispatcher; each request has its own channel to
+       receive the corresponding reply.*/
+    chRequest chan dispatcherRequest
     
     /* When the message is closed, the tracing must be terminated.*/
     chReqTerminate chan struct{}    
     chReplyTerminate chan struct{}  
+
+    // Closes chReqTerminate once.
+    onceTerminate sync.Once
+
+    /* Flush requests: the dispatcher writes the result to the received channel, that must have
+       a capacity of one element, so that the requester is free to stop waiting. */
+    chReqFlush chan chan error
+
+    // Called by the FatalExit functions, after the logger termination.
+    exitFunction func(int)
+
+    // Mutex to access the exitFunction field.
+    mtxExitFunction sync.Mutex
+
+    // What happens to a message when chLogMessages is full.
+    backpressure Backpressure
+
+    // Mutex to access the backpressure field.
+    mtxBackpressure sync.RWMutex
+
+    // The errors reported by the sinks, passed to the error handler by the sinkErrorHandler goroutine.
+    chSinkErrors chan sinkError
+
+    // Called with the errors reported by the sinks; when nil, see isErrorStderrFallback.
+    errorHandler func( sinkId MessageSinkId, err error)
+
+    // Whether the errors are printed on the standard error when there is no error handler.
+    isErrorStderrFallback bool
+
+    // Mutex to access the errorHandler and isErrorStderrFallback fields.
+    mtxErrorHandler sync.Mutex
+
+    // The number of panics after which a sink is quarantined, accessed atomically.
+    sinkPanicLimit int32
 }
 
+// The logger used by the package level functions, replaced by Init() and Reset().
+var defaultLogger *Logger
+
+// Mutex to access the defaultLogger variable.
+var mtxDefaultLogger sync.RWMutex
+
+/* Common state of the message sinks: the severity threshold, the flush policy, the format and
+   the encoding of each message type. */
 type BaseLogMessageSink struct {
     threshold LogSeverity
     isFrequentFlush bool
     messageTypeToFormat map[MessageType]LogFormatItems
+    messageTypeToEncoding map[MessageType]OutputEncoding
+    /* Installed when the sink is added to a logger, see ReportError() and ReportDroppedMessage(); 
+       guarded by the mutex, as sinks running their own goroutine can report errors while the sink
+       is added. */
+    mtxErrorReporter sync.Mutex
+    errorReporter    func(error)
+    dropReporter     func()
+}
+
+/* Creates a base message sink, to be embedded by custom sinks.
+   Log and print messages get the same default formats as the sinks provided by the package. */
+func NewBaseLogMessageSink( threshold LogSeverity, isFrequentFlush bool) BaseLogMessageSink {
+    messageTypeToFormat := map[MessageType]LogFormatItems {
+        LogMessageType:   defaultLogFormat(),
+        PrintMessageType: defaultPrintFormat(),
+    }
+    return BaseLogMessageSink{ threshold: threshold,
+                               isFrequentFlush: isFrequentFlush,
+                               messageTypeToFormat: messageTypeToFormat, }
+}
+
+// Sets the severity threshold of the sink.
+func (b *BaseLogMessageSink) SetSeverity( threshold LogSeverity) {
+    b.threshold= threshold
+}
+
+// Retrieves the severity threshold of the sink.
+func (b *BaseLogMessageSink) Severity() LogSeverity {
+    return b.threshold
 }
 
-func (b *BaseLogMessageSink)setSinkFormat( messageType MessageType, format LogFormatItems) bool {
+// Sets whether the sink must be flushed after each message.
+func (b *BaseLogMessageSink) SetFlush( isFrequentFlush bool) {
+    b.isFrequentFlush= isFrequentFlush
+}
+
+// Determines whether the sink must be flushed after each message.
+func (b *BaseLogMessageSink) IsFrequentFlush() bool {
+    return b.isFrequentFlush
+}
+
+// Sets the format of the given message type.
+func (b *BaseLogMessageSink) SetSinkFormat( messageType MessageType, format LogFormatItems) bool {
+    if b.messageTypeToFormat == nil {
+        b.messageTypeToFormat= make(map[MessageType]LogFormatItems)
+    }
     b.messageTypeToFormat[messageType]= format
     return true
 } 
 
+// Retrieves the format of the given message type.  The boolean is false if no format is set.
+func (b *BaseLogMessageSink) SinkFormat( messageType MessageType) (LogFormatItems, bool) {
+    format, ok := b.messageTypeToFormat[ messageType]
+    return format, ok
+}
+
+// Sets the encoding of the given message type.
+func (b *BaseLogMessageSink) SetSinkEncoding( messageType MessageType, encoding OutputEncoding) bool {
+    switch encoding {
+        case TextEncoding, JSONEncoding, LogfmtEncoding:
+        default:
+            return false
+    }
+    if b.messageTypeToEncoding == nil {
+        b.messageTypeToEncoding= make(map[MessageType]OutputEncoding)
+    }
+    b.messageTypeToEncoding[messageType]= encoding
+    return true
+}
+
+// Retrieves the encoding of the given message type.  By default it is TextEncoding.
+func (b *BaseLogMessageSink) SinkEncoding( messageType MessageType) OutputEncoding {
+    return b.messageTypeToEncoding[ messageType]
+}
+
+/* Reports an error of the sink, e.g. a failed write, to the logger: it is counted by the sink 
+   statistics and passed to the error handler, see SetErrorHandler().  
+   It can be called by any goroutine. */
+func (b *BaseLogMessageSink) ReportError( err error) {
+    if err==nil {
+        return
+    }
+    b.mtxErrorReporter.Lock()
+    reporter := b.errorReporter
+    b.mtxErrorReporter.Unlock()
+    if reporter!=nil {
+        reporter( err)
+    }
+}
+
+// Installs the function called by ReportError(), when the sink is added to a logger.
+func (b *BaseLogMessageSink) setErrorReporter( reporter func(error)) {
+    b.mtxErrorReporter.Lock()
+    defer b.mtxErrorReporter.Unlock()
+    b.errorReporter= reporter
+}
+
+/* Reports a message dropped by the sink, e.g. because it could not be delivered: it is counted by
+   the sink statistics and by the logger, see DroppedMessages().
+   It can be called by any goroutine. */
+func (b *BaseLogMessageSink) ReportDroppedMessage() {
+    b.mtxErrorReporter.Lock()
+    reporter := b.dropReporter
+    b.mtxErrorReporter.Unlock()
+    if reporter!=nil {
+        reporter()
+    }
+}
+
+// Installs the function called by ReportDroppedMessage(), when the sink is added to a logger.
+func (b *BaseLogMessageSink) setDropReporter( reporter func()) {
+    b.mtxErrorReporter.Lock()
+    defer b.mtxErrorReporter.Unlock()
+    b.dropReporter= reporter
+}
+
+/* Formats the message according to the encoding and the format of its message type.
+   If the text encoding is used and there is no format for the message type, the result is the 
+   message text.  The result is always terminated by a new line. */
+func (b *BaseLogMessageSink) FormatMessage( msg *LogMessage) string {
+    switch b.SinkEncoding( msg.messageType) {
+        case JSONEncoding:
+            return encodeJSONLogMessage( msg)
+        case LogfmtEncoding:
+            return encodeLogfmtLogMessage( msg)
+    }
+    format, ok := b.messageTypeToFormat[ msg.messageType ]
+    if ok {
+        return formatLogMessage( msg, &format)
+    }
+    return msg.text+ "\n"
+}
+
 //--------------------------------------------------------------------------------------------------
 func init() {
-    context.severity= DebugSeverity
-    context.chRequest= make(chan interface{})
-    context.chReply= make(chan interface{})
-    context.chLogMessages= make( chan LogMessage, defaultCapChLogMessages)
-    context.chReqTerminate= make( chan struct{}) 
-    context.chReplyTerminate= make( chan struct{}) 
-    go messageDispatcher()
+    defaultLogger= NewLogger()
+}
+
+/* Creates a new logger, with no sinks and the debug severity threshold.
+   The logger must be terminated by calling Terminate(). */
+func NewLogger() *Logger {
+    l := &Logger{ severity: DebugSeverity,
+                  chRequest: make(chan dispatcherRequest),
+                  chLogMessages: make( chan LogMessage, defaultCapChLogMessages),
+                  chReqTerminate: make( chan struct{}),
+                  chReplyTerminate: make( chan struct{}),
+                  chReqFlush: make( chan chan error),
+                  chSinkErrors: make( chan sinkError, defaultCapChSinkErrors),
+                  sinkPanicLimit: defaultSinkPanicLimit,
+                  exitFunction: os.Exit, }
+    go l.messageDispatcher()
+    go l.sinkErrorHandler()
+    return l
+}
+
+/* Retrieves the logger used by the package level functions.  It is replaced by Init() and 
+   Reset(). */
+func DefaultLogger() *Logger {
+    mtxDefaultLogger.RLock()
+    defer mtxDefaultLogger.RUnlock()
+    return defaultLogger
 }
 
 // Determines whether the tracing facility was terminated.
 func IsTerminated() bool {
-    select {
-        case <- context.chReqTerminate:
-            return true
-        default:
-            return false
-    }
+    return DefaultLogger().IsTerminated()
 }
 
-/* Terminate the tracing service.  After termination, all calls to the methods will result in a 
-   fatal.*/
+/* Terminate the tracing service.  After termination, the messages are discarded and the calls on
+   the sinks fail, e.g. AddSink() returns an error and SetMessageSinkSeverity() false, until Init()
+   or Reset() start the default logger again.*/
 func Terminate() {
-    if ! IsTerminated() {    
-        close( context.chReqTerminate)     
-        <- context.chReplyTerminate
-    }
+    DefaultLogger().Terminate()
+}
+
+/* Waits until all the messages issued so far are written by the sinks, and the sinks are
+   flushed.  Returns the errors reported by the sinks. */
+func Flush() error {
+    return DefaultLogger().Flush()
+}
+
+/* Same as Flush(), it stops waiting when ctx is done, returning its error.
+   In that case, the flush is completed in the background. */
+func FlushContext( ctx context.Context) error {
+    return DefaultLogger().FlushContext( ctx)
 }
 
 /* Sets the global severity threshold.  
    Messages below the threshold are not forwarded to the sinks. */
 func SetSeverity( severity LogSeverity){
-    if IsTerminated() {
-      log.Panic( fatalLogTerminated)
-    }
-    
-    context.mtxSeverity.Lock()
-    defer context.mtxSeverity.Unlock()
-    
-    if (severity != context.severity){
-        context.severity= severity
-    }    
+    DefaultLogger().SetSeverity( severity)
 }
 
 // Retrieves the global severity threshold.
 func Severity() LogSeverity {
-    if IsTerminated() {
-      log.Panic( fatalLogTerminated)
-    }
-    
-    context.mtxSeverity.RLock()
-    defer context.mtxSeverity.RUnlock()
-    return context.severity
+    return DefaultLogger().Severity()
 } 
 
 /* Sets the severity of the given sink.*/
 func SetMessageSinkSeverity( sinkId MessageSinkId, threshold LogSeverity) bool {
-    return reqMessageSinkThreshold( sinkId, threshold)
+    return DefaultLogger().SetMessageSinkSeverity( sinkId, threshold)
 }
 
 /* Set the format of for a message type of a given sink.
@@ -183,26 +454,179 @@ func SetMessageSinkSeverity( sinkId MessageSinkId, threshold LogSeverity) bool {
 func SetSinkOutputFormat( sinkId MessageSinkId, 
                           messageType MessageType, 
                           formatItems ...LogFormatItem) bool {
-    return reqSetSinkFormat( sinkId, messageType, formatItems...)
+    return DefaultLogger().SetSinkOutputFormat( sinkId, messageType, formatItems...)
+}
+
+/* Sets the encoding of a message type of a given sink, e.g. JSONEncoding to write one JSON 
+   object per line or LogfmtEncoding to write logfmt lines.  The sink is identified by a valid sinkId. */
+func SetSinkOutputEncoding( sinkId MessageSinkId, 
+                            messageType MessageType, 
+                            encoding OutputEncoding) bool {
+    return DefaultLogger().SetSinkOutputEncoding( sinkId, messageType, encoding)
+}
+
+/* Sets the function called by FatalExit() and FatalExitf() once the logging facility is 
+   terminated.  By default it is os.Exit(); tests can replace it to intercept the exit. */
+func SetExitFunction( exitFunction func(int)) {
+    DefaultLogger().SetExitFunction( exitFunction)
+}
+
+/* Terminates and removes the given sink.  Returns false if no sink has the given sinkId.
+   The identifiers of the other sinks are unchanged. */
+func RemoveSink( sinkId MessageSinkId) bool {
+    return DefaultLogger().RemoveSink( sinkId)
 }
 
 /* Terminate and remove all current sinks. */
 func ClearSinks() bool {
-    return reqClearSinks() 
+    return DefaultLogger().ClearSinks() 
+}
+
+//--------------------------------------------------------------------------------------------------
+// Determines whether the logger was terminated.
+func (l *Logger) IsTerminated() bool {
+    select {
+        case <- l.chReqTerminate:
+            return true
+        default:
+            return false
+    }
+}
+
+/* Terminates the logger: pending messages are delivered to the sinks, then the sinks are 
+   terminated.  After termination, the messages are discarded and the calls on the sinks fail, see
+   Terminate().
+   It can be called by several goroutines: all of them wait for the termination. */
+func (l *Logger) Terminate() {
+    l.onceTerminate.Do( func() { close( l.chReqTerminate) })
+    <- l.chReplyTerminate
+}
+
+/* Waits until all the messages issued so far are written by the sinks, and the sinks are
+   flushed.  Returns the errors reported by the sinks. */
+func (l *Logger) Flush() error {
+    return l.FlushContext( context.Background())
+}
+
+/* Same as Flush(), it stops waiting when ctx is done, returning its error.
+   In that case, the flush is completed in the background. */
+func (l *Logger) FlushContext( ctx context.Context) error {
+    chResult := make( chan error, 1)
+    select {
+        case l.chReqFlush <- chResult:
+        case <- l.chReqTerminate:
+            return errors.New( fatalLogTerminated)
+        case <- ctx.Done():
+            return ctx.Err()
+    }
+    select {
+        case err := <- chResult:
+            return err
+        case <- ctx.Done():
+            return ctx.Err()
+    }
+}
+
+/* Sets the severity threshold of the logger.  
+   Messages below the threshold are not forwarded to the sinks.  It has no effect once the logger
+   is terminated. */
+func (l *Logger) SetSeverity( severity LogSeverity){
+    if l.IsTerminated() {
+        return
+    }
+    
+    l.mtxSeverity.Lock()
+    defer l.mtxSeverity.Unlock()
+    
+    if (severity != l.severity){
+        l.severity= severity
+    }    
+}
+
+// Retrieves the severity threshold of the logger, also once it is terminated.
+func (l *Logger) Severity() LogSeverity {
+    l.mtxSeverity.RLock()
+    defer l.mtxSeverity.RUnlock()
+    return l.severity
+} 
+
+/* Sets the severity of the given sink.*/
+func (l *Logger) SetMessageSinkSeverity( sinkId MessageSinkId, threshold LogSeverity) bool {
+    return l.reqMessageSinkThreshold( sinkId, threshold)
+}
+
+/* Set the format of for a message type of a given sink.
+   The sink is identified by a valid sinkId.
+  formatItems is a sequence of LogFormatItem elements. */
+func (l *Logger) SetSinkOutputFormat( sinkId MessageSinkId, 
+                                      messageType MessageType, 
+                                      formatItems ...LogFormatItem) bool {
+    return l.reqSetSinkFormat( sinkId, messageType, formatItems...)
+}
+
+/* Sets the encoding of a message type of a given sink.
+   The sink is identified by a valid sinkId, and must implement SinkEncodingSetter: otherwise false
+   is returned. */
+func (l *Logger) SetSinkOutputEncoding( sinkId MessageSinkId, 
+                                        messageType MessageType, 
+                                        encoding OutputEncoding) bool {
+    return l.reqSetSinkEncoding( sinkId, messageType, encoding)
+}
+
+/* Sets the function called by the logger FatalExit methods once the logger is terminated.
+   A nil exitFunction restores os.Exit(). */
+func (l *Logger) SetExitFunction( exitFunction func(int)) {
+    if exitFunction == nil {
+        exitFunction= os.Exit
+    }
+    l.mtxExitFunction.Lock()
+    defer l.mtxExitFunction.Unlock()
+    l.exitFunction= exitFunction
+}
+
+/* Terminates and removes the given sink.  Returns false if no sink has the given sinkId.
+   The identifiers of the other sinks are unchanged. */
+func (l *Logger) RemoveSink( sinkId MessageSinkId) bool {
+    return l.reqRemoveSink( sinkId)
+}
+
+/* Terminate and remove all current sinks of the logger. */
+func (l *Logger) ClearSinks() bool {
+    return l.reqClearSinks() 
 }
 
 //--------------------------------------------------------------------------------------------------
-func addLogMessage( text string, 
-                    severity LogSeverity, 
-                    messageType MessageType, 
-                    forcedCaller *callerDetails, 
-                    skip int ) bool {
-    context.mtxSeverity.RLock()
-    defer context.mtxSeverity.RUnlock()
-
-    if severity.IsGreaterOrEqualThan(context.severity) && (! IsTerminated()) {
+// Terminates the logger, so that all messages reach the sinks, then calls the exit function.
+func (l *Logger) terminateAndExit() {
+    l.Terminate()
+    l.mtxExitFunction.Lock()
+    exitFunction := l.exitFunction
+    l.mtxExitFunction.Unlock()
+    exitFunction(1)
+}
+
+//--------------------------------------------------------------------------------------------------
+// Determines whether a message with the given severity would be forwarded to the sinks.
+func (l *Logger) isSeverityEnabled( severity LogSeverity) bool {
+    l.mtxSeverity.RLock()
+    defer l.mtxSeverity.RUnlock()
+    return severity.IsGreaterOrEqualThan(l.severity) && (! l.IsTerminated())
+}
+
+//--------------------------------------------------------------------------------------------------
+func (l *Logger) addLogMessage( text string, 
+                                fields []Field,
+                                severity LogSeverity, 
+                                messageType MessageType, 
+                                forcedCaller *callerDetails, 
+                                skip int ) bool {
+    l.mtxSeverity.RLock()
+    defer l.mtxSeverity.RUnlock()
+
+    if severity.IsGreaterOrEqualThan(l.severity) && (! l.IsTerminated()) {
         var message LogMessage
         message.text= text
+        message.fields= fields
         message.severity= severity
         message.messageType= messageType
         message.timestamp= time.Now()
@@ -219,8 +643,7 @@ func addLogMessage( text string,
             message.line = caller.line
         }
 
-        context.chLogMessages <- message   
-        return true        
+        return l.sendLogMessage( message)
     }
     return false
 }
@@ -283,9 +706,32 @@ func (s LogSeverity) IsGreaterOrEqualThan(that LogSeverity) bool {
 }
 
 //--------------------------------------------------------------------------------------------------
-func addMessageSink(messageSink LogMessageSink) (MessageSinkId,error) {
+/* Adds a custom message sink.
+   In case error is nil, the returned message sink id can be used later to modify the severity
+   threshold and the format. */
+func AddSink( messageSink LogMessageSink) (MessageSinkId, error) {
+    return DefaultLogger().AddSink( messageSink)
+}
+
+/* Adds a custom message sink to the logger.
+   In case error is nil, the returned message sink id can be used later to modify the severity
+   threshold and the format. */
+func (l *Logger) AddSink( messageSink LogMessageSink) (MessageSinkId, error) {
+    if messageSink == nil {
+        return MessageSinkId(0), fmt.Errorf("AddSink(): invalid nil message sink")
+    }
+    return l.reqMessageSink( &messageSink)
+}
+
+//--------------------------------------------------------------------------------------------------
+func (l *Logger) addMessageSink(messageSink LogMessageSink) (MessageSinkId,error) {
     if messageSink == nil {
         panic("addMessageSink(): invalid argument")
     }
-    return reqMessageSink( &messageSink)
+    sinkId, err := l.reqMessageSink( &messageSink)
+    if err!=nil {
+        // The sink was created by the logger: its files and goroutines must not leak.
+        messageSink.Terminate()
+    }
+    return sinkId, err
 }
@@ -0,0 +1,146 @@
+package dmlog
+
+import "bytes"
+import "encoding/json"
+import "fmt"
+import "path/filepath"
+import "strconv"
+import "strings"
+
+// Support for the encodings of the log messages written by the sinks.
+
+// The encoding of the messages written by a sink.
+type OutputEncoding int8
+
+// The supported output encodings.
+const (
+    TextEncoding OutputEncoding = iota  // Plain text, according to the format items of the sink.
+    JSONEncoding // One JSON object per line.
+    LogfmtEncoding // One line of key=value pairs per message, as in logfmt.
+)
+
+const jsonTimestampFormat string = "2006-01-02T15:04:05.000Z07:00"
+
+// Implements the Stringable interface
+func (e OutputEncoding) String() string {
+    switch e {
+        case TextEncoding: return "text"
+        case JSONEncoding: return "json"
+        case LogfmtEncoding: return "logfmt"
+    }
+    return "Unknown"
+}
+
+/* Encodes the log message as a single line JSON object, terminated by a new line.
+   The object has the members timestamp, severity, file, line, function, text and, only when the 
+   message has structured fields, the fields object. */
+func encodeJSONLogMessage( msg *LogMessage) string {
+    if msg==nil {
+        panic("Invalid msg argument.")
+    }
+    var result strings.Builder
+    result.Grow(defaultFormattedLogMessageCapacity)
+    result.WriteString(`{"timestamp":`)
+    result.Write( marshalJSON( msg.timestamp.Format(jsonTimestampFormat)))
+    result.WriteString(`,"severity":`)
+    result.Write( marshalJSON( msg.severity.String()))
+    result.WriteString(`,"file":`)
+    result.Write( marshalJSON( msg.filename))
+    result.WriteString(`,"line":`)
+    result.WriteString( strconv.Itoa( msg.line))
+    result.WriteString(`,"function":`)
+    result.Write( marshalJSON( msg.funcName))
+    result.WriteString(`,"text":`)
+    result.Write( marshalJSON( msg.text))
+    if len(msg.fields)>0 {
+        result.WriteString(`,"fields":{`)
+        for indx, field := range msg.fields {
+            if indx>0 {
+                result.WriteString(",")
+            }
+            result.Write( marshalJSON( field.Key))
+            result.WriteString(":")
+            result.Write( marshalJSONFieldValue( field.Value))
+        }
+        result.WriteString("}")
+    }
+    result.WriteString("}\n")
+    return result.String()
+}
+
+/* Marshals a field value.  Errors and fmt.Stringer values that do not implement json.Marshaler 
+   are encoded as strings; values that cannot be marshalled are encoded using the default format. */
+func marshalJSONFieldValue( value interface{}) []byte {
+    switch v := value.(type) {
+        case json.Marshaler:
+        case error:
+            return marshalJSON( v.Error())
+        case fmt.Stringer:
+            return marshalJSON( v.String())
+    }
+    result, err := tryMarshalJSON( value)
+    if err!=nil {
+        return marshalJSON( fmt.Sprint( value))
+    }
+    return result
+}
+
+// Marshals a value that is known to be valid, like a string.
+func marshalJSON( value interface{}) []byte {
+    result, err := tryMarshalJSON( value)
+    if err!=nil {
+        panic(fmt.Sprintf("marshalJSON() failed: %s", err))
+    }
+    return result
+}
+
+// Marshals a value without escaping the HTML characters, that are common in log messages.
+func tryMarshalJSON( value interface{}) ([]byte, error) {
+    var buffer bytes.Buffer
+    encoder := json.NewEncoder( &buffer)
+    encoder.SetEscapeHTML( false)
+    if err := encoder.Encode( value); err!=nil {
+        return nil, err
+    }
+    return bytes.TrimSuffix( buffer.Bytes(), []byte("\n")), nil
+}
+
+// The keys written by the logfmt encoder for the message itself: fields with these keys are renamed.
+var reservedLogfmtKeys = map[string]bool{ "ts": true, "level": true, "caller": true, "msg": true, }
+
+/* Encodes the log message as a logfmt line, terminated by a new line, e.g.
+   ts=2021-01-06T22:29:40.834Z level=WRN caller=main.go:32 msg="Disk almost full" free=10
+   Values containing spaces, quotes, equal signs or control characters are quoted and escaped.
+   A field whose key is one of the message keys, e.g. msg, gets the field_ prefix, e.g. field_msg. */
+func encodeLogfmtLogMessage( msg *LogMessage) string {
+    if msg==nil {
+        panic("Invalid msg argument.")
+    }
+    var result strings.Builder
+    result.Grow(defaultFormattedLogMessageCapacity)
+    result.WriteString("ts=")
+    result.WriteString( msg.timestamp.Format(jsonTimestampFormat))
+    result.WriteString(" level=")
+    result.WriteString( msg.severity.String())
+    result.WriteString(" caller=")
+    result.WriteString( fieldValueString( filepath.Base( msg.filename)+ ":"+ strconv.Itoa( msg.line)))
+    result.WriteString(" msg=")
+    result.WriteString( fieldValueString( msg.text))
+    for _, field := range msg.fields {
+        result.WriteString(" ")
+        result.WriteString( logfmtFieldKey( field.Key))
+        result.WriteString("=")
+        result.WriteString( fieldValueString( field.Value))
+    }
+    result.WriteString("\n")
+    return result.String()
+}
+
+// Formats the key of a field for the logfmt encoder, renaming the keys of the message itself.
+func logfmtFieldKey( key string) string {
+    key= fieldKeyString( key)
+    if reservedLogfmtKeys[key] {
+        return "field_"+ key
+    }
+    return key
+}
@@ -0,0 +1,73 @@
+package dmlog
+
+import "encoding/json"
+import "errors"
+import "testing"
+import "time"
+
+//--------------------------------------------------------------------------------------------------
+func TestEncodeJSONLogMessage( t *testing.T) {
+    timestamp := time.Date(2021, time.January, 6, 22, 29, 40, 834000000, time.UTC)
+    msg := NewLogMessage("a \"quoted\"\ntext <b>", WarningSeverity, LogMessageType, timestamp, 
+                         "main.go", 32, "main.main", 
+                         NewField("user", 42), 
+                         NewField("latency", 3*time.Millisecond),
+                         NewField("err", errors.New("failed")))
+    got := encodeJSONLogMessage( msg)
+    want := `{"timestamp":"2021-01-06T22:29:40.834Z","severity":"WRN","file":"main.go","line":32,` +
+            `"function":"main.main","text":"a \"quoted\"\ntext <b>",` +
+            `"fields":{"user":42,"latency":"3ms","err":"failed"}}` + "\n"
+    if got!=want {
+        t.Errorf("%s got %q want %q", t.Name(), got, want)
+    }
+    var decoded map[string]interface{}
+    if err := json.Unmarshal( []byte(got), &decoded); err!=nil {
+        t.Error(t.Name(),`json.Unmarshal() failed:`,err)
+    }
+}
+
+//--------------------------------------------------------------------------------------------------
+func TestSinkEncoding( t *testing.T) {
+    timestamp := time.Date(2021, time.January, 6, 22, 29, 40, 834000000, time.UTC)
+    msg := NewLogMessage("text", InfoSeverity, PrintMessageType, timestamp, "main.go", 32, "main.main")
+    base := NewBaseLogMessageSink( DebugSeverity, false)
+    if got := base.FormatMessage( msg); got!="text \n\n" {
+        t.Errorf("%s text encoding: got %q", t.Name(), got)
+    }
+    if ! base.SetSinkEncoding( PrintMessageType, JSONEncoding) {
+        t.Error(t.Name(),`SetSinkEncoding() failed`)
+    }
+    if got := base.FormatMessage( msg); got!=encodeJSONLogMessage( msg) {
+        t.Errorf("%s json encoding: got %q", t.Name(), got)
+    }
+    if base.SetSinkEncoding( PrintMessageType, OutputEncoding(-1)) {
+        t.Error(t.Name(),`SetSinkEncoding() accepted an invalid encoding`)
+    }
+}
+
+//--------------------------------------------------------------------------------------------------
+func TestEncodeLogfmtLogMessage( t *testing.T) {
+    timestamp := time.Date(2021, time.January, 6, 22, 29, 40, 834000000, time.UTC)
+    msg := NewLogMessage("Disk \"sda\" almost full\nretrying", WarningSeverity, LogMessageType, timestamp, 
+                         "/home/user/go/src/main.go", 32, "main.main", 
+                         NewField("free", 10), 
+                         NewField("mount point", "/mnt/a b"),
+                         NewField("empty", ""),
+                         NewField("msg", "field"),
+                         NewField("level", 3))
+    got := encodeLogfmtLogMessage( msg)
+    want := `ts=2021-01-06T22:29:40.834Z level=WRN caller=main.go:32 ` +
+            `msg="Disk \"sda\" almost full\nretrying" free=10 mount_point="/mnt/a b" empty="" ` +
+            `field_msg=field field_level=3` + "\n"
+    if got!=want {
+        t.Errorf("%s got %q want %q", t.Name(), got, want)
+    }
+
+    base := NewBaseLogMessageSink( DebugSeverity, false)
+    if ! base.SetSinkEncoding( LogMessageType, LogfmtEncoding) {
+        t.Error(t.Name(),`SetSinkEncoding() failed`)
+    }
+    if got := base.FormatMessage( msg); got!=want {
+        t.Errorf("%s FormatMessage(): got %q want %q", t.Name(), got, want)
+    }
+}
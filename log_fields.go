@@ -0,0 +1,102 @@
+package dmlog
+
+import "fmt"
+import "strconv"
+import "strings"
+
+// Support for the structured key/value fields of the log messages.
+
+// The value of a key not followed by a value.
+const missingFieldValue string = "<missing>"
+
+// A structured attribute of a log message, like a request id or a latency.
+type Field struct {
+    Key   string
+    Value interface{}
+}
+
+// Creates a field with the given key and value.
+func NewField( key string, value interface{}) Field {
+    return Field{ Key: key, Value: value}
+}
+
+/* Converts a sequence of alternating keys and values into fields.
+   Keys that are not strings are printed using the default format.  Elements that are already a 
+   Field are taken as they are.  A trailing key without a value gets the value "<missing>". */
+func fieldsFromKeysAndValues( keysAndValues []interface{}) []Field {
+    if len(keysAndValues)==0 {
+        return nil
+    }
+    result := make( []Field, 0, (len(keysAndValues)+1)/2)
+    for indx:=0; indx<len(keysAndValues); indx++ {
+        switch key := keysAndValues[indx].(type) {
+            case Field:
+                result= append( result, key)
+            case string:
+                result= append( result, Field{ Key: key, Value: nextFieldValue( keysAndValues, &indx)})
+            default:
+                result= append( result, Field{ Key: fmt.Sprint(key), 
+                                               Value: nextFieldValue( keysAndValues, &indx)})
+        }
+    }
+    return result
+}
+
+// Retrieves the value following the key at *indx, advancing *indx.
+func nextFieldValue( keysAndValues []interface{}, indx *int) interface{} {
+    if *indx+1 >= len(keysAndValues) {
+        return missingFieldValue
+    }
+    *indx++
+    return keysAndValues[*indx]
+}
+
+/* Retrieves the text of a field value, quoted when it is empty or it contains spaces, quotes,
+   equal signs or control characters. */
+func fieldValueString( value interface{}) string {
+    text := fmt.Sprint( value)
+    if needsQuoting( text) {
+        return strconv.Quote( text)
+    }
+    return text
+}
+
+func needsQuoting( text string) bool {
+    if len(text)==0 {
+        return true
+    }
+    for _, r := range text {
+        if r<=' ' || r=='=' || r=='"' || r=='\\' || r==0x7f {
+            return true
+        }
+    }
+    return false
+}
+
+/* Formats the key of a field, replacing the characters that would break the key=value syntax, 
+   i.e. spaces, equal signs, quotes and control characters, with an underscore. */
+func fieldKeyString( key string) string {
+    if len(key)==0 {
+        return "_"
+    }
+    return strings.Map( func(r rune) rune {
+                            if r<=' ' || r=='=' || r=='"' || r=='\\' || r==0x7f {
+                                return '_'
+                            }
+                            return r
+                        }, key)
+}
+
+// Formats the fields as a sequence of key=value pairs separated by a space.
+func formatFields( fields []Field) string {
+    var result strings.Builder
+    for indx, field := range fields {
+        if indx>0 {
+            result.WriteString(" ")
+        }
+        result.WriteString( fieldKeyString( field.Key))
+        result.WriteString("=")
+        result.WriteString( fieldValueString( field.Value))
+    }
+    return result.String()
+}
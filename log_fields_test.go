@@ -0,0 +1,77 @@
+package dmlog
+
+import "reflect"
+import "testing"
+import "time"
+
+//--------------------------------------------------------------------------------------------------
+func TestFieldsFromKeysAndValues( t *testing.T) {
+    var testCases = []struct {
+        keysAndValues []interface{}
+        want []Field
+    }{
+        { nil, nil },
+        { []interface{}{"user", 42}, []Field{ {"user",42} } },
+        { []interface{}{"user", 42, "name", "joe"}, []Field{ {"user",42}, {"name","joe"} } },
+        { []interface{}{NewField("id",7), "k", "v"}, []Field{ {"id",7}, {"k","v"} } },
+        { []interface{}{1, true}, []Field{ {"1",true} } },
+        { []interface{}{"k"}, []Field{ {"k",missingFieldValue} } },
+    }
+    for indx, testCase := range testCases {
+        got := fieldsFromKeysAndValues( testCase.keysAndValues)
+        if !reflect.DeepEqual( got, testCase.want) {
+            t.Error(t.Name(),`failed on test case #`,indx,`got:`,got,`want:`,testCase.want)
+        }
+    }
+}
+
+//--------------------------------------------------------------------------------------------------
+func TestFieldsFmt( t *testing.T) {
+    timestamp := time.Date(2021, time.January, 6, 22, 29, 40, 0, time.UTC)
+    msg := NewLogMessage("served", InfoSeverity, LogMessageType, timestamp, "main.go", 10, "main.main",
+                         NewField("user", 42), NewField("path", "/a b"), NewField("empty", ""),
+                         NewField("user id", 7), NewField("a=b", 1))
+    got := FormatLogMessage( msg, LogFormatItems{SeverityFmt, TextFmt, FieldsFmt})
+    want := `[INF] served user=42 path="/a b" empty="" user_id=7 a_b=1 ` + "\n"
+    if got!=want {
+        t.Errorf("%s got %q want %q", t.Name(), got, want)
+    }
+
+    msg = NewLogMessage("served", InfoSeverity, LogMessageType, timestamp, "main.go", 10, "main.main")
+    got = FormatLogMessage( msg, LogFormatItems{SeverityFmt, TextFmt, FieldsFmt})
+    want = "[INF] served \n"
+    if got!=want {
+        t.Errorf("%s without fields: got %q want %q", t.Name(), got, want)
+    }
+}
+
+//--------------------------------------------------------------------------------------------------
+func TestInfoKV( t *testing.T) {
+    logger := NewLogger()
+    sink := &fieldsRecordingLogMessageSink{ BaseLogMessageSink: NewBaseLogMessageSink( DebugSeverity, false) }
+    if _, err := logger.AddSink( sink); err!=nil {
+        t.Error(t.Name(),`AddSink() failed:`,err)
+        return
+    }
+    logger.InfoKV("request served", "user", "joe", "latency", 3*time.Millisecond)
+    logger.Terminate()
+
+    want := []Field{ {"user","joe"}, {"latency",3*time.Millisecond} }
+    if len(sink.fields)!=1 || !reflect.DeepEqual( sink.fields[0], want) {
+        t.Error(t.Name(),`got:`,sink.fields,`want:`,want)
+    }
+}
+
+// A custom sink that records the fields of the received messages.
+type fieldsRecordingLogMessageSink struct {
+    BaseLogMessageSink
+    fields [][]Field
+}
+
+func (f *fieldsRecordingLogMessageSink) OnLogMessage( msg *LogMessage) {
+    f.fields= append( f.fields, msg.Fields())
+}
+
+func (f *fieldsRecordingLogMessageSink) Flush() {}
+
+func (f *fieldsRecordingLogMessageSink) Terminate() {}
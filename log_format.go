@@ -23,6 +23,7 @@ const (
     SeverityFmt // The log message severity
     TextFmt // The text of the log message.
     LineEndFmt // New line.
+    FieldsFmt // The structured fields, as key=value pairs. Nothing is printed if there are none.
 )
 
 // For each everity it caches the corresponding plain text.
@@ -37,7 +38,7 @@ func defaultPrintFormat() []LogFormatItem {
 
 // Retrieves the default format for plain log messages.
 func defaultLogFormat() []LogFormatItem {
-    return []LogFormatItem{FilenameLineFmt,ShortTimestampFmt,LineEndFmt,SeverityFmt,TextFmt,LineEndFmt}
+    return []LogFormatItem{FilenameLineFmt,ShortTimestampFmt,LineEndFmt,SeverityFmt,TextFmt,FieldsFmt,LineEndFmt}
 }
 
 func init() {
@@ -92,6 +93,11 @@ func formatLogMessage( msg *LogMessage, formatItems *LogFormatItems) string {
                 result.WriteString( severityToText[msg.severity])
             case LineEndFmt:
                 result.WriteString("\n")
+            case FieldsFmt:
+                if len(msg.fields)==0 {
+                    continue
+                }
+                result.WriteString( formatFields( msg.fields))
         }
         if LineEndFmt!=formatItem {
             result.WriteString(" ")
@@ -104,6 +110,12 @@ func formatLogMessage( msg *LogMessage, formatItems *LogFormatItems) string {
     return result.String()
 }
 
+/* Formats the log message according to the format items.
+   It lets the custom sinks produce the same output as the sinks provided by the package. */
+func FormatLogMessage( msg *LogMessage, formatItems LogFormatItems) string {
+    return formatLogMessage( msg, &formatItems)
+}
+
 func timestampString(t time.Time, separator string) string {
     return fmt.Sprintf("%04d%02d%02d%s%02d%02d%02d%s%03d",
                         t.Year(),
@@ -8,53 +8,312 @@ import "fmt"
    function arguments are printed using the default formats. 
    Spaces are added between operands when neither is a string. */
 func Debug(v ...interface{}) bool { 
-    return addLogMessage( fmt.Sprint(v...), DebugSeverity, LogMessageType, nil, defaultSkip)
+    return DefaultLogger().addLogMessage( fmt.Sprint(v...), nil, DebugSeverity, LogMessageType, nil, defaultSkip)
 }
 
 // Issues a warning message.
 func Warn(v ...interface{}) bool { 
-    return addLogMessage( fmt.Sprint(v...), WarningSeverity, LogMessageType, nil, defaultSkip)
+    return DefaultLogger().addLogMessage( fmt.Sprint(v...), nil, WarningSeverity, LogMessageType, nil, defaultSkip)
 }
 
 // Issues an info message.
 func Info(v ...interface{}) bool { 
-    return addLogMessage( fmt.Sprint(v...), InfoSeverity, LogMessageType, nil, defaultSkip)
+    return DefaultLogger().addLogMessage( fmt.Sprint(v...), nil, InfoSeverity, LogMessageType, nil, defaultSkip)
 }
 
 // Prints a log message.
 func Print(v ...interface{}) bool { 
-    return addLogMessage( fmt.Sprint(v...), PrintSeverity, PrintMessageType, nil, defaultSkip)
+    return DefaultLogger().addLogMessage( fmt.Sprint(v...), nil, PrintSeverity, PrintMessageType, nil, defaultSkip)
 }
 
 func LogPrint(v ...interface{}) bool { 
-    return addLogMessage( fmt.Sprint(v...), PrintSeverity, PrintMessageType, nil, defaultSkip)
+    return DefaultLogger().addLogMessage( fmt.Sprint(v...), nil, PrintSeverity, PrintMessageType, nil, defaultSkip)
 }
 
 // Issues a message with error severity level.
 func Error(v ...interface{}) bool { 
-    return addLogMessage( fmt.Sprint(v...), ErrorSeverity, LogMessageType, nil, defaultSkip)
+    return DefaultLogger().addLogMessage( fmt.Sprint(v...), nil, ErrorSeverity, LogMessageType, nil, defaultSkip)
 }
 
-// Issues a message with fatal severity level.
+/* Issues a message with fatal severity level.
+   The program is not terminated: see FatalExit() for that purpose. */
 func Fatal(v ...interface{}) bool { 
-    return addLogMessage( fmt.Sprint(v...), FatalSeverity, LogMessageType, nil, defaultSkip)
+    return DefaultLogger().addLogMessage( fmt.Sprint(v...), nil, FatalSeverity, LogMessageType, nil, defaultSkip)
+}
+
+/* Issues a debug log message with structured fields.
+   In all functions issuing a log message with signature like 
+   func DebugKV(text string, keysAndValues ...interface{}) bool 
+   keysAndValues is a sequence of alternating keys and values, e.g. 
+   InfoKV("request served", "user", userId, "latency", latency). 
+   Field elements can be given in place of a key and its value. */
+func DebugKV(text string, keysAndValues ...interface{}) bool { 
+    return DefaultLogger().addLogMessage( text, fieldsFromKeysAndValues(keysAndValues), DebugSeverity, LogMessageType, nil, defaultSkip)
+}
+
+// Issues a warning message with structured fields.
+func WarnKV(text string, keysAndValues ...interface{}) bool { 
+    return DefaultLogger().addLogMessage( text, fieldsFromKeysAndValues(keysAndValues), WarningSeverity, LogMessageType, nil, defaultSkip)
+}
+
+// Issues an info message with structured fields.
+func InfoKV(text string, keysAndValues ...interface{}) bool { 
+    return DefaultLogger().addLogMessage( text, fieldsFromKeysAndValues(keysAndValues), InfoSeverity, LogMessageType, nil, defaultSkip)
+}
+
+// Issues a message with error severity level and structured fields.
+func ErrorKV(text string, keysAndValues ...interface{}) bool { 
+    return DefaultLogger().addLogMessage( text, fieldsFromKeysAndValues(keysAndValues), ErrorSeverity, LogMessageType, nil, defaultSkip)
+}
+
+// Issues a message with fatal severity level and structured fields.
+func FatalKV(text string, keysAndValues ...interface{}) bool { 
+    return DefaultLogger().addLogMessage( text, fieldsFromKeysAndValues(keysAndValues), FatalSeverity, LogMessageType, nil, defaultSkip)
+}
+
+/* Issues a debug log message formatted according to a format specifier, as in fmt.Printf().
+   In all the functions with signature like 
+   func Debugf(format string, v ...interface{}) bool 
+   the message is formatted only when its severity is not below the global severity threshold. */
+func Debugf(format string, v ...interface{}) bool { 
+    return DefaultLogger().addLogMessagef( format, v, DebugSeverity, LogMessageType, defaultSkip)
+}
+
+// Issues a warning message formatted according to a format specifier.
+func Warnf(format string, v ...interface{}) bool { 
+    return DefaultLogger().addLogMessagef( format, v, WarningSeverity, LogMessageType, defaultSkip)
+}
+
+// Issues an info message formatted according to a format specifier.
+func Infof(format string, v ...interface{}) bool { 
+    return DefaultLogger().addLogMessagef( format, v, InfoSeverity, LogMessageType, defaultSkip)
+}
+
+// Prints a log message formatted according to a format specifier.
+func Printf(format string, v ...interface{}) bool { 
+    return DefaultLogger().addLogMessagef( format, v, PrintSeverity, PrintMessageType, defaultSkip)
+}
+
+// Issues a message with error severity level, formatted according to a format specifier.
+func Errorf(format string, v ...interface{}) bool { 
+    return DefaultLogger().addLogMessagef( format, v, ErrorSeverity, LogMessageType, defaultSkip)
+}
+
+// Issues a message with fatal severity level, formatted according to a format specifier.
+func Fatalf(format string, v ...interface{}) bool { 
+    return DefaultLogger().addLogMessagef( format, v, FatalSeverity, LogMessageType, defaultSkip)
+}
+
+/* Issues a message with fatal severity level, then terminates the logging facility, so that all
+   pending messages are written by the sinks, and finally exits the program with status 1.
+   The exit function can be replaced by means of SetExitFunction(). */
+func FatalExit(v ...interface{}) { 
+    l := DefaultLogger()
+    l.addLogMessage( fmt.Sprint(v...), nil, FatalSeverity, LogMessageType, nil, defaultSkip)
+    l.terminateAndExit()
+}
+
+// Same as FatalExit(), the message is formatted according to a format specifier.
+func FatalExitf(format string, v ...interface{}) { 
+    l := DefaultLogger()
+    l.addLogMessage( fmt.Sprintf(format, v...), nil, FatalSeverity, LogMessageType, nil, defaultSkip)
+    l.terminateAndExit()
+}
+
+/* Issues a message with fatal severity level, waits until all pending messages are written and
+   the sinks are flushed, then panics with the message text. */
+func Panic(v ...interface{}) { 
+    text := fmt.Sprint(v...)
+    l := DefaultLogger()
+    l.addLogMessage( text, nil, FatalSeverity, LogMessageType, nil, defaultSkip)
+    l.Flush()
+    panic( text)
+}
+
+// Same as Panic(), the message is formatted according to a format specifier.
+func Panicf(format string, v ...interface{}) { 
+    text := fmt.Sprintf(format, v...)
+    l := DefaultLogger()
+    l.addLogMessage( text, nil, FatalSeverity, LogMessageType, nil, defaultSkip)
+    l.Flush()
+    panic( text)
 }
 
 // Logs the execution of a method.
 func MethodExecuted() bool {
     var caller callerDetails
     getCallerDetails( &caller, defaultSkip)
-    return addLogMessage( caller.funcName+"() executed", DebugSeverity, LogMessageType, &caller, defaultSkip)
+    return DefaultLogger().addLogMessage( caller.funcName+"() executed", nil, DebugSeverity, LogMessageType, &caller, defaultSkip)
 }
 
 // Logs a method when it starts and terminates.  The returned function must be deferred.
 func MethodStartEnd() func() {
     var caller callerDetails
     getCallerDetails( &caller, defaultSkip)
-    addLogMessage( caller.funcName+ "() started", DebugSeverity, LogMessageType, &caller, defaultSkip)
+    return DefaultLogger().methodStartEnd( &caller)
+}
+
+//--------------------------------------------------------------------------------------------------
+/* Issues a debug log message.
+   Function arguments are printed using the default formats, as for the package level Debug(). */
+func (l *Logger) Debug(v ...interface{}) bool { 
+    return l.addLogMessage( fmt.Sprint(v...), nil, DebugSeverity, LogMessageType, nil, defaultSkip)
+}
+
+// Issues a warning message.
+func (l *Logger) Warn(v ...interface{}) bool { 
+    return l.addLogMessage( fmt.Sprint(v...), nil, WarningSeverity, LogMessageType, nil, defaultSkip)
+}
+
+// Issues an info message.
+func (l *Logger) Info(v ...interface{}) bool { 
+    return l.addLogMessage( fmt.Sprint(v...), nil, InfoSeverity, LogMessageType, nil, defaultSkip)
+}
+
+// Prints a log message.
+func (l *Logger) Print(v ...interface{}) bool { 
+    return l.addLogMessage( fmt.Sprint(v...), nil, PrintSeverity, PrintMessageType, nil, defaultSkip)
+}
+
+// Prints a log message.
+func (l *Logger) LogPrint(v ...interface{}) bool { 
+    return l.addLogMessage( fmt.Sprint(v...), nil, PrintSeverity, PrintMessageType, nil, defaultSkip)
+}
+
+// Issues a message with error severity level.
+func (l *Logger) Error(v ...interface{}) bool { 
+    return l.addLogMessage( fmt.Sprint(v...), nil, ErrorSeverity, LogMessageType, nil, defaultSkip)
+}
+
+// Issues a message with fatal severity level.
+func (l *Logger) Fatal(v ...interface{}) bool { 
+    return l.addLogMessage( fmt.Sprint(v...), nil, FatalSeverity, LogMessageType, nil, defaultSkip)
+}
+
+/* Issues a debug log message with structured fields.
+   In all functions issuing a log message with signature like 
+   func (l *Logger) DebugKV(text string, keysAndValues ...interface{}) bool 
+   keysAndValues is a sequence of alternating keys and values, e.g. 
+   InfoKV("request served", "user", userId, "latency", latency). 
+   Field elements can be given in place of a key and its value. */
+func (l *Logger) DebugKV(text string, keysAndValues ...interface{}) bool { 
+    return l.addLogMessage( text, fieldsFromKeysAndValues(keysAndValues), DebugSeverity, LogMessageType, nil, defaultSkip)
+}
+
+// Issues a warning message with structured fields.
+func (l *Logger) WarnKV(text string, keysAndValues ...interface{}) bool { 
+    return l.addLogMessage( text, fieldsFromKeysAndValues(keysAndValues), WarningSeverity, LogMessageType, nil, defaultSkip)
+}
+
+// Issues an info message with structured fields.
+func (l *Logger) InfoKV(text string, keysAndValues ...interface{}) bool { 
+    return l.addLogMessage( text, fieldsFromKeysAndValues(keysAndValues), InfoSeverity, LogMessageType, nil, defaultSkip)
+}
+
+// Issues a message with error severity level and structured fields.
+func (l *Logger) ErrorKV(text string, keysAndValues ...interface{}) bool { 
+    return l.addLogMessage( text, fieldsFromKeysAndValues(keysAndValues), ErrorSeverity, LogMessageType, nil, defaultSkip)
+}
+
+// Issues a message with fatal severity level and structured fields.
+func (l *Logger) FatalKV(text string, keysAndValues ...interface{}) bool { 
+    return l.addLogMessage( text, fieldsFromKeysAndValues(keysAndValues), FatalSeverity, LogMessageType, nil, defaultSkip)
+}
+
+/* Issues a debug log message formatted according to a format specifier, as in fmt.Printf().
+   In all the functions with signature like 
+   func (l *Logger) Debugf(format string, v ...interface{}) bool 
+   the message is formatted only when its severity is not below the global severity threshold. */
+func (l *Logger) Debugf(format string, v ...interface{}) bool { 
+    return l.addLogMessagef( format, v, DebugSeverity, LogMessageType, defaultSkip)
+}
+
+// Issues a warning message formatted according to a format specifier.
+func (l *Logger) Warnf(format string, v ...interface{}) bool { 
+    return l.addLogMessagef( format, v, WarningSeverity, LogMessageType, defaultSkip)
+}
+
+// Issues an info message formatted according to a format specifier.
+func (l *Logger) Infof(format string, v ...interface{}) bool { 
+    return l.addLogMessagef( format, v, InfoSeverity, LogMessageType, defaultSkip)
+}
+
+// Prints a log message formatted according to a format specifier.
+func (l *Logger) Printf(format string, v ...interface{}) bool { 
+    return l.addLogMessagef( format, v, PrintSeverity, PrintMessageType, defaultSkip)
+}
+
+// Issues a message with error severity level, formatted according to a format specifier.
+func (l *Logger) Errorf(format string, v ...interface{}) bool { 
+    return l.addLogMessagef( format, v, ErrorSeverity, LogMessageType, defaultSkip)
+}
+
+// Issues a message with fatal severity level, formatted according to a format specifier.
+func (l *Logger) Fatalf(format string, v ...interface{}) bool { 
+    return l.addLogMessagef( format, v, FatalSeverity, LogMessageType, defaultSkip)
+}
+
+/* Issues a message with fatal severity level, then terminates the logger, so that all pending 
+   messages are written by the sinks, and finally calls the exit function with status 1. */
+func (l *Logger) FatalExit(v ...interface{}) { 
+    l.addLogMessage( fmt.Sprint(v...), nil, FatalSeverity, LogMessageType, nil, defaultSkip)
+    l.terminateAndExit()
+}
+
+// Same as FatalExit(), the message is formatted according to a format specifier.
+func (l *Logger) FatalExitf(format string, v ...interface{}) { 
+    l.addLogMessage( fmt.Sprintf(format, v...), nil, FatalSeverity, LogMessageType, nil, defaultSkip)
+    l.terminateAndExit()
+}
+
+/* Issues a message with fatal severity level, waits until all pending messages are written and
+   the sinks are flushed, then panics with the message text. */
+func (l *Logger) Panic(v ...interface{}) { 
+    text := fmt.Sprint(v...)
+    l.addLogMessage( text, nil, FatalSeverity, LogMessageType, nil, defaultSkip)
+    l.Flush()
+    panic( text)
+}
+
+// Same as Panic(), the message is formatted according to a format specifier.
+func (l *Logger) Panicf(format string, v ...interface{}) { 
+    text := fmt.Sprintf(format, v...)
+    l.addLogMessage( text, nil, FatalSeverity, LogMessageType, nil, defaultSkip)
+    l.Flush()
+    panic( text)
+}
+
+// Logs the execution of a method.
+func (l *Logger) MethodExecuted() bool {
+    var caller callerDetails
+    getCallerDetails( &caller, defaultSkip)
+    return l.addLogMessage( caller.funcName+"() executed", nil, DebugSeverity, LogMessageType, &caller, defaultSkip)
+}
+
+// Logs a method when it starts and terminates.  The returned function must be deferred.
+func (l *Logger) MethodStartEnd() func() {
+    var caller callerDetails
+    getCallerDetails( &caller, defaultSkip)
+    return l.methodStartEnd( &caller)
+}
+
+//--------------------------------------------------------------------------------------------------
+func (l *Logger) methodStartEnd( caller *callerDetails) func() {
+    l.addLogMessage( caller.funcName+ "() started", nil, DebugSeverity, LogMessageType, caller, defaultSkip)
     return func() {
-        addLogMessage( caller.funcName+"() terminated", DebugSeverity, LogMessageType, &caller, defaultSkip+1)
+        l.addLogMessage( caller.funcName+"() terminated", nil, DebugSeverity, LogMessageType, caller, defaultSkip+1)
     }
 }
 
-
+//--------------------------------------------------------------------------------------------------
+// Formats and issues a log message, unless its severity is below the logger severity threshold.
+func (l *Logger) addLogMessagef( format string, 
+                                 v []interface{}, 
+                                 severity LogSeverity, 
+                                 messageType MessageType, 
+                                 skip int) bool {
+    if ! l.isSeverityEnabled( severity) {
+        return false
+    }
+    return l.addLogMessage( fmt.Sprintf( format, v...), nil, severity, messageType, nil, skip+1)
+}
@@ -1,6 +1,20 @@
 package dmlog
 
+import "errors"
 import "fmt"
+import "strings"
+import "sync/atomic"
+import "time"
+
+// A request to the dispatcher, with the channel where the reply is sent.
+type dispatcherRequest struct {
+    request interface{}
+    chReply chan interface{}
+}
+
+/* A reply completed in the background: the function waits for the sinks to execute the request,
+   then returns the reply. */
+type asyncReply func() interface{}
 
 type replyType struct {
     ok bool
@@ -36,6 +50,50 @@ type replyClearSinksType struct {
     replyType
 }
 
+/* Reopen sinks - request message.  When isReported is true, the errors are reported to the error
+   handler, see SetErrorHandler(), instead of being returned. */
+type reqReopenSinksType struct {
+    isReported bool
+}
+
+// Reopen sinks - reply message.
+type replyReopenSinksType struct {
+    replyType
+    err error
+}
+
+// Sink function - request message: the function is executed on the sink by the dispatcher.
+type reqSinkFunctionType struct {
+    sinkId   MessageSinkId
+    function func( sink LogMessageSink) bool
+}
+
+// Sink function - reply message.
+type replySinkFunctionType struct {
+    replyType
+}
+
+// Sink statistics - request message.
+type reqSinkStatsType struct {
+    sinkId MessageSinkId
+}
+
+// Sink statistics - reply message.
+type replySinkStatsType struct {
+    replyType
+    stats SinkStats
+}
+
+// Remove sink - request message.
+type reqRemoveSinkType struct {
+    sinkId MessageSinkId
+}
+
+// Remove sink - reply message.
+type replyRemoveSinkType struct {
+    replyType
+}
+
 // Set sink format - request message.
 type reqSetSinkFormatType struct {
     sinkId      MessageSinkId
@@ -48,22 +106,69 @@ type replySetSinkFormatType struct {
     replyType
 }
 
+// Set sink encoding - request message.
+type reqSetSinkEncodingType struct {
+    sinkId      MessageSinkId
+    messageType MessageType
+    encoding    OutputEncoding
+}
+
+// Set sink encoding - reply message.
+type replySetSinkEncodingType struct {
+    replyType
+}
+
+//--------------------------------------------------------------------------------------------------
+/* Sends the request to the dispatcher, then waits for the reply.  Returns nil if the logger was
+   terminated: once accepted, a request is always replied. */
+func (l *Logger) sendRequest( request interface{}) interface{} {
+    chReply := make( chan interface{}, 1)
+    select {
+        case l.chRequest <- dispatcherRequest{ request: request, chReply: chReply, }:
+        case <- l.chReqTerminate:
+            return nil
+    }
+    return <- chReply
+}
+
+//--------------------------------------------------------------------------------------------------
 /* Issues a request that sets the format of for a message type of a given sink.
  * The sink is identified by the sinkId, that must be previously added.
  * formatItems is a sequence of LogFormatItem elements.
  */
-func reqSetSinkFormat(sinkId MessageSinkId, 
-                      messageType MessageType, 
-                      formatItems ...LogFormatItem) bool {
-    context.chRequest <- reqSetSinkFormatType { 
+func (l *Logger) reqSetSinkFormat(sinkId MessageSinkId, 
+                                  messageType MessageType, 
+                                  formatItems ...LogFormatItem) bool {
+    switch reply := l.sendRequest( reqSetSinkFormatType { 
                             sinkId: sinkId,
                             messageType: messageType,
                             formatItems: formatItems,
-                        }
-    switch reply := (<- context.chReply).(type) {
+                        }).(type) {
         case replySetSinkFormatType: {
             return reply.ok
         }       
+        case nil:
+            return false
+        default:
+            panic(fmt.Sprintf("unexpected reply type %T",reply))
+    }
+}
+
+//--------------------------------------------------------------------------------------------------
+// Issues a request that sets the encoding of a message type of a given sink. 
+func (l *Logger) reqSetSinkEncoding(sinkId MessageSinkId, 
+                                    messageType MessageType, 
+                                    encoding OutputEncoding) bool {
+    switch reply := l.sendRequest( reqSetSinkEncodingType { 
+                            sinkId: sinkId,
+                            messageType: messageType,
+                            encoding: encoding,
+                        }).(type) {
+        case replySetSinkEncodingType: {
+            return reply.ok
+        }       
+        case nil:
+            return false
         default:
             panic(fmt.Sprintf("unexpected reply type %T",reply))
     }
@@ -71,12 +176,70 @@ func reqSetSinkFormat(sinkId MessageSinkId,
 
 //--------------------------------------------------------------------------------------------------
 // Issues a request to remove all sinks.  It blocks waiting for the result. 
-func reqClearSinks() bool {
-    context.chRequest <- reqClearSinksType{ }
-    switch reply := (<- context.chReply).(type) {
+func (l *Logger) reqClearSinks() bool {
+    switch reply := l.sendRequest( reqClearSinksType{ }).(type) {
         case replyClearSinksType: {
             return reply.ok
         }       
+        case nil:
+            return false
+        default:
+            panic(fmt.Sprintf("unexpected reply type %T",reply))
+    }
+}
+
+//--------------------------------------------------------------------------------------------------
+// Issues a request to reopen the sinks that support it.  It blocks waiting for the result. 
+func (l *Logger) reqReopenSinks( isReported bool) error {
+    switch reply := l.sendRequest( reqReopenSinksType{ isReported: isReported}).(type) {
+        case replyReopenSinksType: {
+            return reply.err
+        }       
+        case nil:
+            return errors.New( fatalLogTerminated)
+        default:
+            panic(fmt.Sprintf("unexpected reply type %T",reply))
+    }
+}
+
+//--------------------------------------------------------------------------------------------------
+/* Issues a request to execute a function on a sink, in the dispatcher goroutine.  
+   It blocks waiting for the result, i.e. false if the sink does not exist or the function failed.*/
+func (l *Logger) reqSinkFunction( sinkId MessageSinkId, function func( sink LogMessageSink) bool) bool {
+    switch reply := l.sendRequest( reqSinkFunctionType{ sinkId: sinkId, function: function, }).(type) {
+        case replySinkFunctionType: {
+            return reply.ok
+        }       
+        case nil:
+            return false
+        default:
+            panic(fmt.Sprintf("unexpected reply type %T",reply))
+    }
+}
+
+//--------------------------------------------------------------------------------------------------
+// Issues a request to retrieve the statistics of a sink.  It blocks waiting for the result. 
+func (l *Logger) reqSinkStats( sinkId MessageSinkId) (SinkStats, bool) {
+    switch reply := l.sendRequest( reqSinkStatsType{ sinkId: sinkId, }).(type) {
+        case replySinkStatsType: {
+            return reply.stats, reply.ok
+        }       
+        case nil:
+            return SinkStats{}, false
+        default:
+            panic(fmt.Sprintf("unexpected reply type %T",reply))
+    }
+}
+
+//--------------------------------------------------------------------------------------------------
+// Issues a request to terminate and remove a sink.  It blocks waiting for the result. 
+func (l *Logger) reqRemoveSink( sinkId MessageSinkId) bool {
+    switch reply := l.sendRequest( reqRemoveSinkType{ sinkId: sinkId, }).(type) {
+        case replyRemoveSinkType: {
+            return reply.ok
+        }       
+        case nil:
+            return false
         default:
             panic(fmt.Sprintf("unexpected reply type %T",reply))
     }
@@ -84,18 +247,19 @@ func reqClearSinks() bool {
 
 //--------------------------------------------------------------------------------------------------
 // Issues a request to add a message sink.  It blocks waiting for the result. 
-func reqMessageSink( messageSink *LogMessageSink) (MessageSinkId, error) {
+func (l *Logger) reqMessageSink( messageSink *LogMessageSink) (MessageSinkId, error) {
     if messageSink == nil {
         return MessageSinkId(0), fmt.Errorf("reqMessageSink(): invalid argument")
     }
-    context.chRequest <- reqMessageSinkType{ messageSink:messageSink,}
-    switch reply := (<- context.chReply).(type) {
+    switch reply := l.sendRequest( reqMessageSinkType{ messageSink:messageSink,}).(type) {
         case replyMessageSinkType: {
             if ! reply.ok {
               return MessageSinkId(0),fmt.Errorf("failed")
             }
             return reply.sinkId, nil
         }       
+        case nil:
+            return MessageSinkId(0), errors.New( fatalLogTerminated)
         default:
             panic(fmt.Sprintf("unexpected reply type %T",reply))
     }
@@ -103,55 +267,90 @@ func reqMessageSink( messageSink *LogMessageSink) (MessageSinkId, error) {
 
 //--------------------------------------------------------------------------------------------------
 // Issues a request to set a sink threshold.  It blocks waiting for the result. 
-func reqMessageSinkThreshold( sinkId MessageSinkId, threshold LogSeverity) bool {
-    context.chRequest <- reqMessageSinkThresholdType{ sinkId:sinkId, threshold:threshold,}
-    switch reply := (<- context.chReply).(type) {
+func (l *Logger) reqMessageSinkThreshold( sinkId MessageSinkId, threshold LogSeverity) bool {
+    switch reply := l.sendRequest( reqMessageSinkThresholdType{ sinkId:sinkId, threshold:threshold,}).(type) {
         case replyMessageSinkThresholdType: {
             return reply.ok
         }       
+        case nil:
+            return false
         default:
             panic(fmt.Sprintf("unexpected reply type %T",reply))
     }
 }
 
+// The errors returned by one or more sinks, and by Flush() for the dropped messages.
+type sinkErrors []error
+
+func (s sinkErrors) Error() string {
+    texts := make( []string, 0, len(s))
+    for _, err := range s {
+        texts= append( texts, err.Error())
+    }
+    return strings.Join( texts, "; ")
+}
+
+// Retrieves the errors of the single sinks.
+func (s sinkErrors) Unwrap() []error {
+    return s
+}
+
 type ctxMessageDispatcher struct {
-    sinks []*LogMessageSink
+    sinks []*sinkEntry
+
+    // The identifier of the next added sink: identifiers are never reused.
+    nextSinkId MessageSinkId
+
+    // The number of dropped messages already reported by a warning message.
+    reportedDroppedMessages uint64
 }
 
 //--------------------------------------------------------------------------------------------------
-func messageDispatcher() {
-    var ctx = ctxMessageDispatcher{ sinks: make([]*LogMessageSink, 0, defaultSinksCapacity), }
+func (l *Logger) messageDispatcher() {
+    var ctx = ctxMessageDispatcher{ sinks: make([]*sinkEntry, 0, defaultSinksCapacity), }
+    dropReportTicker := time.NewTicker( dropReportInterval)
+    defer dropReportTicker.Stop()
         
     for isTerminate:=false; !isTerminate; {
         select {
-            case newMessage := <- context.chLogMessages: {
-                for _, sink := range ctx.sinks {
-                    (*sink).OnLogMessage( &newMessage)
+            case newMessage := <- l.chLogMessages: {
+                l.dispatchLogMessage( &ctx, newMessage, false)
+            }
+
+            case newRequest := <- l.chRequest: {
+                // The request applies after the messages issued before it.
+                l.dispatchPendingMessages( &ctx, false)
+                reply := l.handleRequest( newRequest.request, &ctx)
+                if waitReply, ok := reply.(asyncReply); ok {
+                    // A slow sink must not stall the dispatcher: the reply is sent in the background.
+                    go func() { newRequest.chReply <- waitReply() }()
+                } else {
+                    newRequest.chReply <- reply
                 }
             }
 
-            case newRequest := <- context.chRequest: {
-                context.chReply <- handleRequest( newRequest, &ctx)
+            case <- dropReportTicker.C: {
+                l.reportDroppedMessages( &ctx, false)
             }
 
-            case <- context.chReqTerminate: {
-                stillHasMessages := true
-                for stillHasMessages {   
-                    // Flushes all pending messages.
-                    select {
-                        case newMessage := <- context.chLogMessages: {
-                            for _, sink := range ctx.sinks {
-                                (*sink).OnLogMessage( &newMessage)
-                            }
-                        }
-                        default:
-                            stillHasMessages= false
-                    }
-                }
-                for _, sink := range ctx.sinks {
-                    (*sink).terminate()
+            case chResult := <- l.chReqFlush: {
+                l.dispatchPendingMessages( &ctx, false)
+                l.reportDroppedMessages( &ctx, false)
+                // A slow sink must not stall the dispatcher: the flush completes in the background.
+                waitFlush := submitFlush( ctx.sinks)
+                go func() {
+                    errFlush := waitFlush()
+                    chResult <- joinFlushErrors( l.flushDroppedMessages(), errFlush)
+                }()
+            }
+
+            case <- l.chReqTerminate: {
+                l.dispatchPendingMessages( &ctx, true)
+                l.reportDroppedMessages( &ctx, true)
+                for _, entry := range ctx.sinks {
+                    entry.terminate()
                 }
-                close(context.chReplyTerminate)
+                close(l.chReplyTerminate)
                 isTerminate = true                
             }    
         }
@@ -159,38 +358,247 @@ func messageDispatcher() {
 }
 
 //--------------------------------------------------------------------------------------------------
-func handleRequest( request interface{}, ctx *ctxMessageDispatcher) interface{} {
+/* Queues the message for all the sinks, see sinkEntry.post().  Returns false if at least one sink
+   dropped it. */
+func dispatchMessage( ctx *ctxMessageDispatcher, message LogMessage, isBlocking bool) bool {
+    isQueued := true
+    for _, entry := range ctx.sinks {
+        if !entry.post( &message, isBlocking) {
+            isQueued= false
+        }
+    }
+    return isQueued
+}
+
+//--------------------------------------------------------------------------------------------------
+// Queues the message for all the sinks, counting it as dropped if at least one sink dropped it.
+func (l *Logger) dispatchLogMessage( ctx *ctxMessageDispatcher, message LogMessage, isBlocking bool) {
+    if !dispatchMessage( ctx, message, isBlocking) {
+        atomic.AddUint64( &l.droppedMessages, 1)
+    }
+}
+
+//--------------------------------------------------------------------------------------------------
+// Delivers to the sinks all the messages waiting in the channel.
+func (l *Logger) dispatchPendingMessages( ctx *ctxMessageDispatcher, isBlocking bool) {
+    for stillHasMessages := true; stillHasMessages; {   
+        select {
+            case newMessage := <- l.chLogMessages: {
+                l.dispatchLogMessage( ctx, newMessage, isBlocking)
+            }
+            default:
+                stillHasMessages= false
+        }
+    }
+}
+
+//--------------------------------------------------------------------------------------------------
+/* Queues the flush of all the sinks, after the messages already queued.  The returned function 
+   waits for the sinks to be flushed, and returns nil if all of them succeeded. */
+func submitFlush( sinks []*sinkEntry) func() error {
+    return submitToSinks( sinks, flushSink)
+}
+
+//--------------------------------------------------------------------------------------------------
+// Flushes the sink, returning the error of FlushError() if the sink implements ErrorFlusher.
+func flushSink( sink LogMessageSink) error {
+    if flusher, ok := sink.(ErrorFlusher); ok {
+        return flusher.FlushError()
+    }
+    sink.Flush()
+    return nil
+}
+
+//--------------------------------------------------------------------------------------------------
+// Joins the error for the dropped messages and the errors of the sinks flush, either can be nil.
+func joinFlushErrors( errDropped, errFlush error) error {
+    if errDropped==nil {
+        return errFlush
+    }
+    result := sinkErrors{ errDropped}
+    if errs, ok := errFlush.(sinkErrors); ok {
+        result= append( result, errs...)
+    } else if errFlush!=nil {
+        result= append( result, errFlush)
+    }
+    return result
+}
+
+//--------------------------------------------------------------------------------------------------
+/* Queues the function to be executed on each sink, see sinkEntry.submit().  The returned function
+   waits for the executions, and returns the errors of the sinks; nil if all of them succeeded. */
+func submitToSinks( sinks []*sinkEntry, function func( sink LogMessageSink) error) func() error {
+    errs := make( []error, len(sinks))
+    waits := make( []func() bool, len(sinks))
+    for indx, entry := range sinks {
+        indx, entry := indx, entry
+        waits[indx]= entry.submit( func() {
+                                       // A panic is the error of the sink, then it is handled as usual.
+                                       defer func() {
+                                           if recovered := recover(); recovered!=nil {
+                                               errs[indx]= fmt.Errorf("the sink panicked: %v", recovered)
+                                               panic( recovered)
+                                           }
+                                       }()
+                                       errs[indx]= function( *entry.sink)
+                                   }, false)
+    }
+    return func() error {
+        var result sinkErrors
+        for indx, wait := range waits {
+            wait()
+            if errs[indx]!=nil {
+                result= append( result, fmt.Errorf("sink %d: %w", sinks[indx].id, errs[indx]))
+            }
+        }
+        if len(result)==0 {
+            return nil
+        }
+        return result
+    }
+}
+
+//--------------------------------------------------------------------------------------------------
+// Retrieves the entry of the given sink, nil if it does not exist.
+func findSink( ctx *ctxMessageDispatcher, sinkId MessageSinkId) *sinkEntry {
+    for _, entry := range ctx.sinks {
+        if entry.id == sinkId {
+            return entry
+        }
+    }
+    return nil
+}
+
+//--------------------------------------------------------------------------------------------------
+/* Handles a request.  The sinks are accessed by commands executed in their goroutines, after the
+   messages already queued: the commands are queued at once, while the reply is completed in the
+   background, see asyncReply, so that a stalled sink does not block the dispatcher. */
+func (l *Logger) handleRequest( request interface{}, ctx *ctxMessageDispatcher) interface{} {
     switch request := request.(type) {
         case reqMessageSinkType: {
-            ctx.sinks= append(ctx.sinks, request.messageSink )
-            newSinkId := MessageSinkId( len(ctx.sinks)-1 )                        
+            newSinkId := ctx.nextSinkId
+            ctx.nextSinkId++
+            ctx.sinks= append(ctx.sinks, newSinkEntry( l, newSinkId, request.messageSink))
             return replyMessageSinkType{ replyType{true}, newSinkId}
         }
         case reqMessageSinkThresholdType: {
-            for indx, sink := range ctx.sinks {
-                if MessageSinkId(indx)== request.sinkId {
-                    (*sink).SetSeverity( request.threshold)
-                    return replyMessageSinkThresholdType{ replyType{true} }
-                }                   
+            entry := findSink( ctx, request.sinkId)
+            if entry==nil {
+                return replyMessageSinkThresholdType{ replyType{false} }
             }
-            return replyMessageSinkThresholdType{ replyType{false} }
+            wait := entry.submit( func() { (*entry.sink).SetSeverity( request.threshold) }, false)
+            return asyncReply( func() interface{} {
+                return replyMessageSinkThresholdType{ replyType{ wait()} }
+            })
         }
         case reqClearSinksType: {
-            for _, sink := range ctx.sinks {
-                    (*sink).terminate()
+            waits := make( []func(), 0, len(ctx.sinks))
+            for _, entry := range ctx.sinks {
+                waits= append( waits, entry.submitTerminate())
             }
-            ctx.sinks= make([]*LogMessageSink, 0, defaultSinksCapacity)
-
-            return replyClearSinksType{ replyType{true}, }
+            ctx.sinks= make([]*sinkEntry, 0, defaultSinksCapacity)
+            return asyncReply( func() interface{} {
+                for _, wait := range waits {
+                    wait()
+                }
+                return replyClearSinksType{ replyType{true}, }
+            })
         }
         case reqSetSinkFormatType: {
-            for indx, sink := range ctx.sinks {
-                if MessageSinkId(indx)== request.sinkId {
-                    isOk := (*sink).setSinkFormat( request.messageType, request.formatItems)
-                    return replySetSinkFormatType{ replyType{isOk}, }
+            entry := findSink( ctx, request.sinkId)
+            if entry==nil {
+                return replySetSinkFormatType{ replyType{false}, }
+            }
+            isOk := false
+            wait := entry.submit( func() { 
+                isOk= (*entry.sink).SetSinkFormat( request.messageType, request.formatItems) 
+            }, false)
+            return asyncReply( func() interface{} {
+                wait()
+                return replySetSinkFormatType{ replyType{isOk}, }
+            })
+        }  
+        case reqSetSinkEncodingType: {
+            entry := findSink( ctx, request.sinkId)
+            if entry==nil {
+                return replySetSinkEncodingType{ replyType{false}, }
+            }
+            setter, isSetter := (*entry.sink).(SinkEncodingSetter)
+            if ! isSetter {
+                return replySetSinkEncodingType{ replyType{false}, }
+            }
+            isOk := false
+            wait := entry.submit( func() { 
+                isOk= setter.SetSinkEncoding( request.messageType, request.encoding) 
+            }, false)
+            return asyncReply( func() interface{} {
+                wait()
+                return replySetSinkEncodingType{ replyType{isOk}, }
+            })
+        }  
+        case reqReopenSinksType: {
+            reopen := func( sink LogMessageSink) error {
+                if reopenable, ok := sink.(ReopenableLogMessageSink); ok {
+                    return reopenable.Reopen()
+                }
+                return nil
+            }
+            if request.isReported {
+                waits := make( []func() bool, 0, len(ctx.sinks))
+                for _, entry := range ctx.sinks {
+                    entry := entry
+                    waits= append( waits, entry.submit( func() {
+                                                  if err := reopen( *entry.sink); err!=nil {
+                                                      l.reportSinkError( entry, err)
+                                                  }
+                                              }, false))
+                }
+                return asyncReply( func() interface{} {
+                    for _, wait := range waits {
+                        wait()
+                    }
+                    return replyReopenSinksType{ replyType{true}, nil}
+                })
+            }
+            waitReopen := submitToSinks( ctx.sinks, reopen)
+            return asyncReply( func() interface{} {
+                if err := waitReopen(); err!=nil {
+                    return replyReopenSinksType{ replyType{false}, err}
+                }
+                return replyReopenSinksType{ replyType{true}, nil}
+            })
+        }
+        case reqSinkFunctionType: {
+            entry := findSink( ctx, request.sinkId)
+            if entry==nil {
+                return replySinkFunctionType{ replyType{false}, }
+            }
+            isOk := false
+            wait := entry.submit( func() { isOk= request.function( *entry.sink) }, false)
+            return asyncReply( func() interface{} {
+                wait()
+                return replySinkFunctionType{ replyType{isOk}, }
+            })
+        }
+        case reqSinkStatsType: {
+            entry := findSink( ctx, request.sinkId)
+            if entry==nil {
+                return replySinkStatsType{ replyType{false}, SinkStats{}}
+            }
+            return replySinkStatsType{ replyType{true}, entry.stats()}
+        }
+        case reqRemoveSinkType: {
+            for indx, entry := range ctx.sinks {
+                if entry.id == request.sinkId {
+                    wait := entry.submitTerminate()
+                    ctx.sinks= append( ctx.sinks[:indx], ctx.sinks[indx+1:]...)
+                    return asyncReply( func() interface{} {
+                        wait()
+                        return replyRemoveSinkType{ replyType{true}, }
+                    })
                 }                   
             }
-            return replySetSinkFormatType{ replyType{false}, }
+            return replyRemoveSinkType{ replyType{false}, }
         }  
         default : {
             return replyType{false}
@@ -1,8 +1,12 @@
 package dmlog
 
+import "bytes"
+import "context"
+import "errors"
 import "fmt"
 import "log"
 import "os"
+import "strings"
 import "testing"
 import "time"
 
@@ -120,10 +124,48 @@ func TestConsole( t *testing.T) {
     LogPrint("Print message n:",n)
     mySimpleFunction()
     myTracedFunction()
-    time.Sleep(100*time.Millisecond)
+    Flush()
     ClearSinks()
 }
 
+//--------------------------------------------------------------------------------------------------
+func TestConsoleSplit( t *testing.T) {
+    var stdout, stderr bytes.Buffer
+    msgSink := newConsoleLogMessageSink( DebugSeverity, false)
+    msgSink.stdout= &stdout
+    msgSink.stderr= &stderr
+    msgSink.SetSinkFormat( LogMessageType, LogFormatItems{ TextFmt})
+
+    logger := NewLogger()
+    sinkId, err := logger.addMessageSink( msgSink)
+    if err!=nil {
+        t.Error(t.Name(),`addMessageSink() failed:`,err)
+        return
+    }
+    logger.Info("Info 1")
+    logger.Warn("Warning 1")
+    if !logger.SetConsoleSplit( sinkId, true, WarningSeverity) {
+        t.Error(t.Name(),`SetConsoleSplit() failed`)
+    }
+    logger.Info("Info 2")
+    logger.Print("Print message")
+    logger.Warn("Warning 2")
+    logger.Error("Error message")
+    logger.Terminate()
+
+    expected := "Info 1 \nWarning 1 \nInfo 2 \nPrint message \n\n"
+    if stdout.String()!=expected {
+        t.Errorf("%s Unexpected stdout: got: %q expected: %q", t.Name(), stdout.String(), expected)
+    }
+    expected = "Warning 2 \nError message \n"
+    if stderr.String()!=expected {
+        t.Errorf("%s Unexpected stderr: got: %q expected: %q", t.Name(), stderr.String(), expected)
+    }
+    if SetConsoleSplit( MessageSinkId(1000), true, WarningSeverity) {
+        t.Error(t.Name(),`SetConsoleSplit() succeeded on a missing sink`)
+    }
+}
+
 func aFunction() {
     defer MethodStartEnd()
 }
@@ -183,3 +225,348 @@ func ExampleSetSinkOutputFormat(){
     Debug("Debug log message")
 }
 
+
+// A custom sink that records the text of the received messages.
+type recordingLogMessageSink struct {
+    BaseLogMessageSink
+    texts []string
+    isTerminated bool
+}
+
+func (r *recordingLogMessageSink) OnLogMessage( msg *LogMessage) {
+    if msg.Severity().IsGreaterOrEqualThan( r.Severity()) {
+        r.texts= append( r.texts, msg.Text())
+    }
+}
+
+func (r *recordingLogMessageSink) Flush() {}
+
+func (r *recordingLogMessageSink) Terminate() {
+    r.isTerminated= true
+}
+
+//--------------------------------------------------------------------------------------------------
+func TestAddSink( t *testing.T) {
+    _, err := AddSink( nil)
+    if err==nil {
+        t.Error(t.Name(),`AddSink(nil): got nil error`)
+    }
+
+    sink := &recordingLogMessageSink{ BaseLogMessageSink: NewBaseLogMessageSink( InfoSeverity, false) }
+    sinkId, err := AddSink( sink)
+    if err!=nil {
+        t.Error(t.Name(),`AddSink() failed:`,err)
+        return
+    }
+    if ! SetSinkOutputFormat( sinkId, LogMessageType, TextFmt) {
+        t.Error(t.Name(),`SetSinkOutputFormat() failed`)
+    }
+    Debug("Debug message")
+    Info("Info message")
+    Flush()
+    ClearSinks()
+
+    if ! sink.isTerminated {
+        t.Error(t.Name(),`the sink was not terminated`)
+    }
+    want := []string{"Info message"}
+    if len(sink.texts)!=len(want) || sink.texts[0]!=want[0] {
+        t.Error(t.Name(),`got`,sink.texts,`want`,want)
+    }
+    if format, ok := sink.SinkFormat( LogMessageType); !ok || len(format)!=1 || format[0]!=TextFmt {
+        t.Error(t.Name(),`SinkFormat(): got`,format,ok)
+    }
+}
+
+// A custom sink implementing only the LogMessageSink methods, without the optional capabilities.
+type minimalLogMessageSink struct {
+    threshold LogSeverity
+    numMessages int
+    numFlushes int
+}
+
+func (m *minimalLogMessageSink) SetSeverity( threshold LogSeverity) { m.threshold= threshold }
+func (m *minimalLogMessageSink) Severity() LogSeverity { return m.threshold }
+func (m *minimalLogMessageSink) OnLogMessage( msg *LogMessage) { m.numMessages++ }
+func (m *minimalLogMessageSink) Flush() { m.numFlushes++ }
+func (m *minimalLogMessageSink) SetSinkFormat( messageType MessageType, format LogFormatItems) bool {
+    return true
+}
+func (m *minimalLogMessageSink) SetFlush( isFrequentFlush bool) {}
+func (m *minimalLogMessageSink) Terminate() {}
+
+//--------------------------------------------------------------------------------------------------
+func TestAddMinimalSink( t *testing.T) {
+    logger := NewLogger()
+    defer logger.Terminate()
+    sink := &minimalLogMessageSink{ threshold: DebugSeverity }
+    sinkId, err := logger.AddSink( sink)
+    if err!=nil {
+        t.Error(t.Name(),`AddSink() failed:`,err)
+        return
+    }
+    if logger.SetSinkOutputEncoding( sinkId, LogMessageType, JSONEncoding) {
+        t.Error(t.Name(),`SetSinkOutputEncoding() succeeded without SinkEncodingSetter`)
+    }
+    logger.Info("Info message")
+    if err := logger.Flush(); err!=nil {
+        t.Error(t.Name(),`Flush() failed:`,err)
+    }
+    if sink.numMessages!=1 || sink.numFlushes!=1 {
+        t.Error(t.Name(),`got`,sink.numMessages,`messages and`,sink.numFlushes,`flushes, want 1 and 1`)
+    }
+}
+
+//--------------------------------------------------------------------------------------------------
+func TestLogMessageAccessors( t *testing.T) {
+    timestamp := time.Date(2021, time.January, 6, 22, 29, 40, 834000000, time.UTC)
+    msg := NewLogMessage("A message", WarningSeverity, LogMessageType, timestamp, "main.go", 32, "main.main")
+    if msg.Text()!="A message" || msg.Severity()!=WarningSeverity || msg.MessageType()!=LogMessageType {
+        t.Error(t.Name(),`unexpected text, severity or type:`,msg.Text(),msg.Severity(),msg.MessageType())
+    }
+    if !msg.Timestamp().Equal(timestamp) || msg.Filename()!="main.go" || msg.Line()!=32 || 
+       msg.FuncName()!="main.main" {
+        t.Error(t.Name(),`unexpected caller details:`,msg.Timestamp(),msg.Filename(),msg.Line(),msg.FuncName())
+    }
+
+    got := FormatLogMessage( msg, LogFormatItems{FilenameLineFmt,ShortTimestampFmt,SeverityFmt,TextFmt})
+    want := "main.go:32 2021-01-06 22:29:40.834 [WRN] A message \n"
+    if got!=want {
+        t.Errorf("%s FormatLogMessage(): got %q want %q", t.Name(), got, want)
+    }
+}
+
+//--------------------------------------------------------------------------------------------------
+func TestIndependentLoggers( t *testing.T) {
+    t.Parallel()
+    logger1 := NewLogger()
+    logger2 := NewLogger()
+    sink1 := &recordingLogMessageSink{ BaseLogMessageSink: NewBaseLogMessageSink( DebugSeverity, false) }
+    sink2 := &recordingLogMessageSink{ BaseLogMessageSink: NewBaseLogMessageSink( DebugSeverity, false) }
+    if _, err := logger1.AddSink( sink1); err!=nil {
+        t.Error(t.Name(),`AddSink() failed:`,err)
+        return
+    }
+    if _, err := logger2.AddSink( sink2); err!=nil {
+        t.Error(t.Name(),`AddSink() failed:`,err)
+        return
+    }
+    logger2.SetSeverity( WarningSeverity)
+
+    logger1.Debug("Debug message 1")
+    logger2.Debug("Debug message 2")
+    logger2.Warn("Warning message 2")
+    logger1.Terminate()
+    if logger2.IsTerminated() {
+        t.Error(t.Name(),`logger2 terminated together with logger1`)
+    }
+    logger2.Terminate()
+
+    if len(sink1.texts)!=1 || sink1.texts[0]!="Debug message 1" {
+        t.Error(t.Name(),`logger1: unexpected messages`,sink1.texts)
+    }
+    if len(sink2.texts)!=1 || sink2.texts[0]!="Warning message 2" {
+        t.Error(t.Name(),`logger2: unexpected messages`,sink2.texts)
+    }
+    if ! sink1.isTerminated || ! sink2.isTerminated {
+        t.Error(t.Name(),`the sinks were not terminated`)
+    }
+}
+
+// Counts how many times it is formatted.
+type countingStringer struct {
+    count int
+}
+
+func (c *countingStringer) String() string {
+    c.count++
+    return "counted"
+}
+
+// A custom sink that records the received messages.
+type messagesRecordingLogMessageSink struct {
+    BaseLogMessageSink
+    messages []LogMessage
+}
+
+func (m *messagesRecordingLogMessageSink) OnLogMessage( msg *LogMessage) {
+    m.messages= append( m.messages, *msg)
+}
+
+func (m *messagesRecordingLogMessageSink) Flush() {}
+
+func (m *messagesRecordingLogMessageSink) Terminate() {}
+
+//--------------------------------------------------------------------------------------------------
+func TestPrintfFunctions( t *testing.T) {
+    logger := NewLogger()
+    sink := &messagesRecordingLogMessageSink{ BaseLogMessageSink: NewBaseLogMessageSink( DebugSeverity, false) }
+    if _, err := logger.AddSink( sink); err!=nil {
+        t.Error(t.Name(),`AddSink() failed:`,err)
+        return
+    }
+    logger.SetSeverity( InfoSeverity)
+    stringer := &countingStringer{}
+    if logger.Debugf("below threshold %s", stringer) {
+        t.Error(t.Name(),`Debugf(): got true, want false`)
+    }
+    if stringer.count!=0 {
+        t.Error(t.Name(),`Debugf() formatted a message below the threshold`)
+    }
+    var caller callerDetails
+    getCallerDetails( &caller, 0)
+    logger.Warnf("value %d of %s", 3, stringer)
+    logger.Terminate()
+
+    if len(sink.messages)!=1 {
+        t.Error(t.Name(),`got`,len(sink.messages),`messages, want 1`)
+        return
+    }
+    msg := sink.messages[0]
+    if msg.Text()!="value 3 of counted" || msg.Severity()!=WarningSeverity {
+        t.Error(t.Name(),`unexpected message:`,msg.Text(),msg.Severity())
+    }
+    if msg.Filename()!=caller.filename || msg.Line()!=caller.line+1 {
+        t.Error(t.Name(),`unexpected caller: got`,msg.Filename(),msg.Line(),`want`,caller.filename,caller.line+1)
+    }
+}
+
+//--------------------------------------------------------------------------------------------------
+func TestFatalExit( t *testing.T) {
+    logger := NewLogger()
+    sink := &recordingLogMessageSink{ BaseLogMessageSink: NewBaseLogMessageSink( DebugSeverity, false) }
+    if _, err := logger.AddSink( sink); err!=nil {
+        t.Error(t.Name(),`AddSink() failed:`,err)
+        return
+    }
+    exitCode := -1
+    logger.SetExitFunction( func(code int) { exitCode= code })
+    logger.Debug("Debug message")
+    logger.FatalExitf("Fatal message %d", 1)
+
+    if exitCode!=1 {
+        t.Error(t.Name(),`exit code: got`,exitCode,`want 1`)
+    }
+    if ! logger.IsTerminated() || ! sink.isTerminated {
+        t.Error(t.Name(),`the logger or the sink were not terminated`)
+    }
+    want := []string{"Debug message", "Fatal message 1"}
+    if len(sink.texts)!=len(want) || sink.texts[0]!=want[0] || sink.texts[1]!=want[1] {
+        t.Error(t.Name(),`got`,sink.texts,`want`,want)
+    }
+}
+
+//--------------------------------------------------------------------------------------------------
+func TestPanic( t *testing.T) {
+    logger := NewLogger()
+    defer logger.Terminate()
+    sink := &recordingLogMessageSink{ BaseLogMessageSink: NewBaseLogMessageSink( DebugSeverity, false) }
+    if _, err := logger.AddSink( sink); err!=nil {
+        t.Error(t.Name(),`AddSink() failed:`,err)
+        return
+    }
+    defer func() {
+        recovered := recover()
+        if recovered!="Panic message 2" {
+            t.Error(t.Name(),`recover(): got`,recovered)
+        }
+        if len(sink.texts)!=1 || sink.texts[0]!="Panic message 2" {
+            t.Error(t.Name(),`the message was not delivered before the panic:`,sink.texts)
+        }
+    }()
+    logger.Panic("Panic message ", 2)
+}
+
+// A custom sink whose flush always fails.
+type failingFlushLogMessageSink struct {
+    recordingLogMessageSink
+}
+
+func (f *failingFlushLogMessageSink) FlushError() error {
+    return errors.New("flush failed")
+}
+
+//--------------------------------------------------------------------------------------------------
+func TestFlush( t *testing.T) {
+    logger := NewLogger()
+    sink := &recordingLogMessageSink{ BaseLogMessageSink: NewBaseLogMessageSink( DebugSeverity, false) }
+    if _, err := logger.AddSink( sink); err!=nil {
+        t.Error(t.Name(),`AddSink() failed:`,err)
+        return
+    }
+    for indx:=0; indx<10; indx++ {
+        logger.Debug("Debug message ", indx)
+    }
+    if err := logger.Flush(); err!=nil {
+        t.Error(t.Name(),`Flush() failed:`,err)
+    }
+    if len(sink.texts)!=10 {
+        t.Error(t.Name(),`got`,len(sink.texts),`messages after Flush(), want 10`)
+    }
+
+    failingSink := &failingFlushLogMessageSink{ recordingLogMessageSink{ 
+                                                BaseLogMessageSink: NewBaseLogMessageSink( DebugSeverity, false) } }
+    if _, err := logger.AddSink( failingSink); err!=nil {
+        t.Error(t.Name(),`AddSink() failed:`,err)
+        return
+    }
+    err := logger.FlushContext( context.Background())
+    if err==nil || !strings.Contains( err.Error(), "sink 1: flush failed") {
+        t.Error(t.Name(),`FlushContext(): unexpected error`,err)
+    }
+
+    logger.Terminate()
+    if err := logger.Flush(); err==nil {
+        t.Error(t.Name(),`Flush() after Terminate(): got nil error`)
+    }
+}
+
+//--------------------------------------------------------------------------------------------------
+func TestRemoveSink( t *testing.T) {
+    logger := NewLogger()
+    defer logger.Terminate()
+    sinks := make( []*recordingLogMessageSink, 3)
+    sinkIds := make( []MessageSinkId, 3)
+    for indx := range sinks {
+        sinks[indx]= &recordingLogMessageSink{ BaseLogMessageSink: NewBaseLogMessageSink( DebugSeverity, false) }
+        sinkId, err := logger.AddSink( sinks[indx])
+        if err!=nil {
+            t.Error(t.Name(),`AddSink() failed:`,err)
+            return
+        }
+        sinkIds[indx]= sinkId
+    }
+    if ! logger.RemoveSink( sinkIds[1]) {
+        t.Error(t.Name(),`RemoveSink() failed`)
+    }
+    if ! sinks[1].isTerminated {
+        t.Error(t.Name(),`the removed sink was not terminated`)
+    }
+    if logger.RemoveSink( sinkIds[1]) {
+        t.Error(t.Name(),`RemoveSink() succeeded twice on the same sink`)
+    }
+    // The identifiers of the remaining sinks still address the same sinks.
+    if ! logger.SetMessageSinkSeverity( sinkIds[2], WarningSeverity) {
+        t.Error(t.Name(),`SetMessageSinkSeverity() failed`)
+    }
+    if logger.SetMessageSinkSeverity( sinkIds[1], WarningSeverity) {
+        t.Error(t.Name(),`SetMessageSinkSeverity() succeeded on a removed sink`)
+    }
+    newSink := &recordingLogMessageSink{ BaseLogMessageSink: NewBaseLogMessageSink( DebugSeverity, false) }
+    newSinkId, err := logger.AddSink( newSink)
+    if err!=nil {
+        t.Error(t.Name(),`AddSink() failed:`,err)
+        return
+    }
+    for _, sinkId := range sinkIds {
+        if newSinkId == sinkId {
+            t.Error(t.Name(),`AddSink() reused the sink id`,sinkId)
+        }
+    }
+
+    logger.Info("Info message")
+    logger.Flush()
+    if len(sinks[0].texts)!=1 || len(sinks[1].texts)!=0 || len(sinks[2].texts)!=0 || len(newSink.texts)!=1 {
+        t.Error(t.Name(),`unexpected messages:`,sinks[0].texts,sinks[1].texts,sinks[2].texts,newSink.texts)
+    }
+}
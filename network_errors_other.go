@@ -0,0 +1,9 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package dmlog
+
+import "syscall"
+
+// The errors of a message too large to be sent as a datagram.
+var messageSizeErrors = []error{ syscall.EMSGSIZE}
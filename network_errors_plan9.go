@@ -0,0 +1,7 @@
+//go:build plan9
+// +build plan9
+
+package dmlog
+
+// The errors of a message too large to be sent as a datagram: none is known on this platform.
+var messageSizeErrors []error
@@ -0,0 +1,9 @@
+//go:build windows
+// +build windows
+
+package dmlog
+
+import "syscall"
+
+// The errors of a message too large to be sent as a datagram; 10040 is WSAEMSGSIZE.
+var messageSizeErrors = []error{ syscall.EMSGSIZE, syscall.Errno(10040)}
@@ -0,0 +1,429 @@
+package dmlog
+
+import "encoding/binary"
+import "errors"
+import "fmt"
+import "io"
+import "io/ioutil"
+import "net"
+import "os"
+import "time"
+
+const defaultNetworkQueueCapacity int = 100
+const defaultNetworkDialTimeout = 5*time.Second
+const defaultNetworkWriteTimeout = 5*time.Second
+const defaultMinReconnectDelay = 100*time.Millisecond
+const defaultMaxReconnectDelay = 30*time.Second
+const defaultMaxSpillSize KBytes = 10*1024
+
+// The size of the header of each message stored into the spill file: the message length.
+const spillRecordHeaderSize = 4
+
+// Implementation of a log sink that streams messages to a collector over a network connection.
+type networkLogMessageSink struct {
+    BaseLogMessageSink
+
+    network           string
+    address           string
+    options           NetworkSinkOptions
+    conn              net.Conn
+    // The delay before the next connection attempt, doubled after each failure.
+    reconnectDelay    time.Duration
+    reconnectTimer    *time.Timer
+    spillFile         *os.File
+    spillSize         Bytes
+    // Set when a message is dropped because the spill file is full, to report it once.
+    isSpillFull       bool
+    /* Set when a message is dropped because the sink is disconnected and there is no spill file, to
+       report it once per disconnection. */
+    isDroppingDisconnected bool
+
+    chStrLog          chan string
+    chReqFlush        chan chan error
+    chReqTerminate    chan struct{}
+    chReplyTerminate  chan struct{}
+}
+
+/* Optional settings of the network sink.
+   The zero value of each member selects its default. */
+type NetworkSinkOptions struct {
+    /* The number of messages waiting to be sent by the goroutine of the sink.  The sink keeps its own
+       queue because that goroutine also schedules the reconnections, driven by timers, while the
+       messages keep arriving.  When this queue is full, the messages wait in the queue of the sink
+       in the logger, then the backpressure policy of the logger applies, see Backpressure. */
+    QueueCapacity int
+
+    // The timeout of each connection attempt.
+    DialTimeout time.Duration
+
+    // The timeout of each write; when it expires, the connection is considered broken.
+    WriteTimeout time.Duration
+
+    /* After a failed connection attempt, the next one is done after MinReconnectDelay, doubled
+       after each failure up to MaxReconnectDelay. */
+    MinReconnectDelay time.Duration
+    MaxReconnectDelay time.Duration
+
+    /* While disconnected, the messages are stored into SpillFilename and sent when the connection
+       is restored, before any new message.  Messages found in the file when the sink is created,
+       e.g. by a previous run of the program, are sent as well.
+       When empty, the messages issued while disconnected are dropped. */
+    SpillFilename string
+
+    // When the spill file reaches MaxSpillSize, further messages are dropped; default 10 MBytes.
+    MaxSpillSize KBytes
+}
+
+/* Adds a log message sink that sends the formatted messages to a collector, e.g. a local agent.
+   network is "tcp", "udp", "unix" or "unixgram", or one of their variants accepted by net.Dial().
+   Over a stream connection messages are terminated by a new line; over a datagram connection each
+   message is a datagram.  The messages are written as text or, by SetSinkOutputEncoding(), JSON
+   or logfmt.
+   The sink connects in the background and connects again when the connection breaks.
+   In case error is nil, the returned message sink id can be used later to modify the severity
+   threshold.*/
+func AddNetworkSink( network string, address string, threshold LogSeverity) (MessageSinkId, error) {
+    return DefaultLogger().AddNetworkSinkWithOptions( network, address, threshold, NetworkSinkOptions{})
+}
+
+// Same as AddNetworkSink(), with the optional settings, e.g. the spill file.
+func AddNetworkSinkWithOptions( network string,
+                                address string,
+                                threshold LogSeverity,
+                                options NetworkSinkOptions) (MessageSinkId, error) {
+    return DefaultLogger().AddNetworkSinkWithOptions( network, address, threshold, options)
+}
+
+// Adds to the logger a sink that sends messages to a collector, see AddNetworkSink().
+func (l *Logger) AddNetworkSink( network string,
+                                 address string,
+                                 threshold LogSeverity) (MessageSinkId, error) {
+    return l.AddNetworkSinkWithOptions( network, address, threshold, NetworkSinkOptions{})
+}
+
+// Adds to the logger a network sink with the optional settings, see AddNetworkSinkWithOptions().
+func (l *Logger) AddNetworkSinkWithOptions( network string,
+                                            address string,
+                                            threshold LogSeverity,
+                                            options NetworkSinkOptions) (MessageSinkId, error) {
+    msgSink, err := newNetworkLogMessageSink( network, address, threshold, options)
+    if err!=nil {
+        return MessageSinkId(0), err
+    }
+    return l.addMessageSink( msgSink)
+}
+
+//--------------------------------------------------------------------------------------------------
+func newNetworkLogMessageSink( network string,
+                               address string,
+                               threshold LogSeverity,
+                               options NetworkSinkOptions) (*networkLogMessageSink, error) {
+    switch network {
+        case "tcp", "tcp4", "tcp6", "udp", "udp4", "udp6", "unix", "unixgram":
+        default:
+            return nil, fmt.Errorf("invalid network %s", network)
+    }
+    if len(address)==0 {
+        return nil, fmt.Errorf("invalid empty address")
+    }
+    if options.QueueCapacity<0 || options.DialTimeout<0 || options.WriteTimeout<0 ||
+       options.MinReconnectDelay<0 || options.MaxReconnectDelay<0 {
+        return nil, fmt.Errorf("invalid negative option")
+    }
+    if options.QueueCapacity==0 {
+        options.QueueCapacity= defaultNetworkQueueCapacity
+    }
+    if options.DialTimeout==0 {
+        options.DialTimeout= defaultNetworkDialTimeout
+    }
+    if options.WriteTimeout==0 {
+        options.WriteTimeout= defaultNetworkWriteTimeout
+    }
+    if options.MinReconnectDelay==0 {
+        options.MinReconnectDelay= defaultMinReconnectDelay
+    }
+    if options.MaxReconnectDelay==0 {
+        options.MaxReconnectDelay= defaultMaxReconnectDelay
+    }
+    if options.MaxReconnectDelay<options.MinReconnectDelay {
+        options.MaxReconnectDelay= options.MinReconnectDelay
+    }
+    if options.MaxSpillSize==0 {
+        options.MaxSpillSize= defaultMaxSpillSize
+    }
+
+    result := networkLogMessageSink{ BaseLogMessageSink: NewBaseLogMessageSink( threshold, false),
+                                     network: network,
+                                     address: address,
+                                     options: options,
+                                     reconnectDelay: options.MinReconnectDelay,
+                                     chStrLog: make( chan string, options.QueueCapacity),
+                                     chReqFlush: make( chan chan error),
+                                     chReqTerminate: make( chan struct{}),
+                                     chReplyTerminate: make( chan struct{}), }
+    if len(options.SpillFilename)>0 {
+        spillFile, err := os.OpenFile( options.SpillFilename, os.O_RDWR|os.O_CREATE, 0666)
+        if err!=nil {
+            return nil, fmt.Errorf("failed while trying to open the spill file %s:%s",
+                                   options.SpillFilename, err)
+        }
+        spillSize, err := spillFile.Seek( 0, io.SeekEnd)
+        if err!=nil {
+            spillFile.Close()
+            return nil, fmt.Errorf("failed while trying to seek the spill file %s:%s",
+                                   options.SpillFilename, err)
+        }
+        result.spillFile= spillFile
+        result.spillSize= Bytes(spillSize)
+    }
+    go networkSinkHandler( &result)
+    return &result, nil
+}
+
+//--------------------------------------------------------------------------------------------------
+func (n *networkLogMessageSink) OnLogMessage( msg *LogMessage) {
+    if msg.severity.IsGreaterOrEqualThan( n.threshold) {
+        n.chStrLog <- n.FormatMessage( msg)
+    }
+}
+
+//--------------------------------------------------------------------------------------------------
+// Same as FlushError(), the error is discarded.
+func (n *networkLogMessageSink) Flush() {
+    n.FlushError()
+}
+
+//--------------------------------------------------------------------------------------------------
+// Waits until the queued messages are sent or stored into the spill file, then syncs the latter.
+func (n *networkLogMessageSink) FlushError() error {
+    chResult := make( chan error, 1)
+    n.chReqFlush <- chResult
+    return <- chResult
+}
+
+//--------------------------------------------------------------------------------------------------
+func (n *networkLogMessageSink) Terminate() {
+    close( n.chReqTerminate)
+    <- n.chReplyTerminate
+}
+
+//--------------------------------------------------------------------------------------------------
+func networkSinkHandler( ctx *networkLogMessageSink) {
+    networkSinkConnect( ctx)
+    for terminate:= false; !terminate; {
+        var chReconnect <-chan time.Time
+        if ctx.reconnectTimer!=nil {
+            chReconnect= ctx.reconnectTimer.C
+        }
+        select {
+            case strLog := <- ctx.chStrLog:
+                networkSinkOnNewStrLog( ctx, strLog)
+            case <- chReconnect:
+                ctx.reconnectTimer= nil
+                networkSinkConnect( ctx)
+            case chResult := <- ctx.chReqFlush:
+                networkSinkWritePending( ctx)
+                if ctx.spillFile!=nil {
+                    chResult <- ctx.spillFile.Sync()
+                } else {
+                    chResult <- nil
+                }
+            case <- ctx.chReqTerminate:
+                networkSinkWritePending( ctx)
+                if ctx.reconnectTimer!=nil {
+                    ctx.reconnectTimer.Stop()
+                }
+                if ctx.conn!=nil {
+                    ctx.conn.Close()
+                }
+                if ctx.spillFile!=nil {
+                    if err := ctx.spillFile.Close(); err!=nil {
+                        ctx.ReportError( fmt.Errorf("failed while trying to close the spill file %s:%w",
+                                                    ctx.options.SpillFilename, err))
+                    }
+                }
+                terminate= true
+        }
+    }
+    close( ctx.chReplyTerminate)
+}
+
+//--------------------------------------------------------------------------------------------------
+// Sends or spills the messages waiting in the channel.
+func networkSinkWritePending( ctx *networkLogMessageSink) {
+    for hasLogStrings:= true; hasLogStrings; {
+        select {
+            case strLog := <- ctx.chStrLog:
+                networkSinkOnNewStrLog( ctx, strLog)
+            default:
+                hasLogStrings = false
+        }
+    }
+}
+
+//--------------------------------------------------------------------------------------------------
+/* Sends the message.  While disconnected, or while older messages are still in the spill file, the
+   message is spilled so that the order is kept.  A message that cannot be sent on a working 
+   connection, e.g. larger than a datagram, is dropped. */
+func networkSinkOnNewStrLog( ctx *networkLogMessageSink, strMessage string) {
+    if ctx.conn!=nil && ctx.spillSize==0 {
+        err := networkSinkSend( ctx, []byte(strMessage))
+        if err==nil {
+            return
+        }
+        if isMessageSizeError( err) {
+            ctx.ReportDroppedMessage()
+            ctx.ReportError( fmt.Errorf("message dropped:%w", err))
+            return
+        }
+        ctx.ReportError( err)
+        networkSinkDisconnect( ctx)
+    }
+    networkSinkSpill( ctx, strMessage)
+}
+
+//--------------------------------------------------------------------------------------------------
+func networkSinkSend( ctx *networkLogMessageSink, data []byte) error {
+    ctx.conn.SetWriteDeadline( time.Now().Add( ctx.options.WriteTimeout))
+    if _, err := ctx.conn.Write( data); err!=nil {
+        return fmt.Errorf("failed while trying to send to %s %s:%w", ctx.network, ctx.address, err)
+    }
+    return nil
+}
+
+// Determines whether the error is due to the message, too large to be sent, and not to the connection.
+func isMessageSizeError( err error) bool {
+    for _, messageSizeError := range messageSizeErrors {
+        if errors.Is( err, messageSizeError) {
+            return true
+        }
+    }
+    return false
+}
+
+//--------------------------------------------------------------------------------------------------
+/* Connects to the collector and sends the spilled messages.  On failure, the next attempt is
+   scheduled with an exponential backoff.  Only the first failure of a sequence is reported. */
+func networkSinkConnect( ctx *networkLogMessageSink) {
+    conn, err := net.DialTimeout( ctx.network, ctx.address, ctx.options.DialTimeout)
+    if err==nil {
+        ctx.conn= conn
+        ctx.isDroppingDisconnected= false
+        if err = networkSinkReplay( ctx); err==nil {
+            ctx.reconnectDelay= ctx.options.MinReconnectDelay
+            return
+        }
+        ctx.ReportError( err)
+        networkSinkDisconnect( ctx)
+        return
+    }
+    if ctx.reconnectDelay==ctx.options.MinReconnectDelay {
+        ctx.ReportError( fmt.Errorf("failed while trying to connect to %s %s:%w", 
+                                    ctx.network, ctx.address, err))
+    }
+    ctx.reconnectTimer= time.NewTimer( ctx.reconnectDelay)
+    ctx.reconnectDelay= nextReconnectDelay( ctx.reconnectDelay, &ctx.options)
+}
+
+//--------------------------------------------------------------------------------------------------
+// Closes the broken connection and schedules the next connection attempt.
+func networkSinkDisconnect( ctx *networkLogMessageSink) {
+    ctx.conn.Close()
+    ctx.conn= nil
+    ctx.reconnectTimer= time.NewTimer( ctx.reconnectDelay)
+    ctx.reconnectDelay= nextReconnectDelay( ctx.reconnectDelay, &ctx.options)
+}
+
+// Retrieves the delay following the given one: the double, at most the maximum delay.
+func nextReconnectDelay( delay time.Duration, options *NetworkSinkOptions) time.Duration {
+    delay*= 2
+    if delay>options.MaxReconnectDelay {
+        return options.MaxReconnectDelay
+    }
+    return delay
+}
+
+//--------------------------------------------------------------------------------------------------
+/* Appends the message to the spill file, preceded by its length so that datagrams are replayed as
+   they were.  Without a spill file, or when it is full, the message is dropped. */
+func networkSinkSpill( ctx *networkLogMessageSink, strMessage string) {
+    recordSize := Bytes( spillRecordHeaderSize+ len(strMessage))
+    if ctx.spillFile==nil {
+        ctx.ReportDroppedMessage()
+        if !ctx.isDroppingDisconnected {
+            ctx.isDroppingDisconnected= true
+            ctx.ReportError( fmt.Errorf("disconnected from %s %s, messages are dropped",
+                                        ctx.network, ctx.address))
+        }
+        return
+    }
+    if ctx.spillSize+recordSize > Bytes(ctx.options.MaxSpillSize)*kBytesToBytes {
+        ctx.ReportDroppedMessage()
+        if !ctx.isSpillFull {
+            ctx.isSpillFull= true
+            ctx.ReportError( fmt.Errorf("the spill file %s is full, messages are dropped",
+                                        ctx.options.SpillFilename))
+        }
+        return
+    }
+    record := make( []byte, recordSize)
+    binary.BigEndian.PutUint32( record, uint32( len(strMessage)))
+    copy( record[spillRecordHeaderSize:], strMessage)
+    if _, err := ctx.spillFile.WriteAt( record, int64(ctx.spillSize)); err!=nil {
+        ctx.ReportDroppedMessage()
+        ctx.ReportError( fmt.Errorf("failed while trying to write to the spill file %s:%w",
+                                    ctx.options.SpillFilename, err))
+        return
+    }
+    ctx.spillSize+= recordSize
+}
+
+//--------------------------------------------------------------------------------------------------
+/* Sends the messages stored into the spill file, then empties it.  If sending fails, the messages
+   not sent yet are kept; a message too large to be sent is dropped. */
+func networkSinkReplay( ctx *networkLogMessageSink) error {
+    if ctx.spillFile==nil || ctx.spillSize==0 {
+        return nil
+    }
+    if _, err := ctx.spillFile.Seek( 0, io.SeekStart); err!=nil {
+        return err
+    }
+    records, err := ioutil.ReadAll( io.LimitReader( ctx.spillFile, int64(ctx.spillSize)))
+    if err!=nil {
+        return err
+    }
+    offset := 0
+    for offset+spillRecordHeaderSize <= len(records) {
+        recordLen := int( binary.BigEndian.Uint32( records[offset:]))
+        recordEnd := offset+ spillRecordHeaderSize+ recordLen
+        if recordEnd>len(records) {
+            // A truncated record, e.g. after a crash: it is discarded.
+            break
+        }
+        if err = networkSinkSend( ctx, records[offset+spillRecordHeaderSize:recordEnd]); err!=nil {
+            if !isMessageSizeError( err) {
+                break
+            }
+            ctx.ReportDroppedMessage()
+            ctx.ReportError( fmt.Errorf("spilled message dropped:%w", err))
+            err= nil
+        }
+        offset= recordEnd
+    }
+    remaining := records[offset:]
+    if err!=nil && len(remaining)>0 {
+        if _, errWrite := ctx.spillFile.WriteAt( remaining, 0); errWrite!=nil {
+            return errWrite
+        }
+    } else {
+        remaining= nil
+    }
+    if errTruncate := ctx.spillFile.Truncate( int64(len(remaining))); errTruncate!=nil {
+        return errTruncate
+    }
+    ctx.spillSize= Bytes( len(remaining))
+    if ctx.spillSize==0 {
+        ctx.isSpillFull= false
+    }
+    return err
+}
@@ -0,0 +1,257 @@
+package dmlog
+
+import "bufio"
+import "encoding/json"
+import "errors"
+import "io/ioutil"
+import "net"
+import "os"
+import "path/filepath"
+import "strings"
+import "testing"
+import "time"
+
+//--------------------------------------------------------------------------------------------------
+func TestNextReconnectDelay( t *testing.T) {
+    options := NetworkSinkOptions{ MinReconnectDelay: 100*time.Millisecond,
+                                   MaxReconnectDelay: 300*time.Millisecond, }
+    delay := options.MinReconnectDelay
+    expected := []time.Duration{ 200*time.Millisecond, 300*time.Millisecond, 300*time.Millisecond}
+    for _, expectedDelay := range expected {
+        delay= nextReconnectDelay( delay, &options)
+        if delay!=expectedDelay {
+            t.Error(t.Name(),`Unexpected delay: got:`,delay,`expected:`,expectedDelay)
+        }
+    }
+}
+
+//--------------------------------------------------------------------------------------------------
+func TestNetworkSinkTCP( t *testing.T) {
+    listener, err := net.Listen("tcp", "127.0.0.1:0")
+    if err!=nil {
+        t.Error(t.Name(),`Listen() failed:`,err)
+        return
+    }
+    defer listener.Close()
+
+    logger := NewLogger()
+    defer logger.Terminate()
+    sinkId, err := logger.AddNetworkSink("tcp", listener.Addr().String(), InfoSeverity)
+    if err!=nil {
+        t.Error(t.Name(),`AddNetworkSink() failed:`,err)
+        return
+    }
+    logger.SetSinkOutputEncoding( sinkId, LogMessageType, JSONEncoding)
+    conn, err := listener.Accept()
+    if err!=nil {
+        t.Error(t.Name(),`Accept() failed:`,err)
+        return
+    }
+    defer conn.Close()
+
+    logger.Debug("Debug message")
+    logger.InfoKV("Info message", "user", 42)
+    conn.SetReadDeadline( time.Now().Add( 5*time.Second))
+    line, err := bufio.NewReader( conn).ReadString('\n')
+    if err!=nil {
+        t.Error(t.Name(),`ReadString() failed:`,err)
+        return
+    }
+    var object map[string]interface{}
+    if err := json.Unmarshal( []byte(line), &object); err!=nil {
+        t.Error(t.Name(),`Unmarshal() failed:`,err,line)
+        return
+    }
+    if object["text"]!="Info message" || object["severity"]!=InfoSeverity.String() {
+        t.Error(t.Name(),`Unexpected message:`,line)
+    }
+}
+
+//--------------------------------------------------------------------------------------------------
+func TestNetworkSinkSpill( t *testing.T) {
+    dirPath, err := ioutil.TempDir("", "log_network_sink_test_")
+    if err!=nil {
+        t.Error(t.Name(),`TempDir() failed:`,err)
+        return
+    }
+    defer os.RemoveAll( dirPath)
+
+    // Finds a free port, nobody listens on it until the messages are spilled.
+    listener, err := net.Listen("tcp", "127.0.0.1:0")
+    if err!=nil {
+        t.Error(t.Name(),`Listen() failed:`,err)
+        return
+    }
+    address := listener.Addr().String()
+    listener.Close()
+
+    spillFilename := filepath.Join( dirPath, "spill")
+    logger := NewLogger()
+    defer logger.Terminate()
+    sinkId, err := logger.AddNetworkSinkWithOptions("tcp", address, InfoSeverity,
+                                     NetworkSinkOptions{ SpillFilename: spillFilename,
+                                                         MinReconnectDelay: 10*time.Millisecond,
+                                                         MaxReconnectDelay: 50*time.Millisecond, })
+    if err!=nil {
+        t.Error(t.Name(),`AddNetworkSinkWithOptions() failed:`,err)
+        return
+    }
+    logger.SetSinkOutputFormat( sinkId, LogMessageType, TextFmt)
+    logger.Info("First message")
+    logger.Info("Second\nmessage")
+    if err := logger.Flush(); err!=nil {
+        t.Error(t.Name(),`Flush() failed:`,err)
+    }
+    fileInfo, err := os.Stat( spillFilename)
+    if err!=nil || fileInfo.Size()==0 {
+        t.Error(t.Name(),`Unexpected spill file:`,fileInfo,err)
+        return
+    }
+
+    listener, err = net.Listen("tcp", address)
+    if err!=nil {
+        t.Error(t.Name(),`Listen() failed:`,err)
+        return
+    }
+    defer listener.Close()
+    conn, err := listener.Accept()
+    if err!=nil {
+        t.Error(t.Name(),`Accept() failed:`,err)
+        return
+    }
+    defer conn.Close()
+    logger.Info("Third message")
+
+    conn.SetReadDeadline( time.Now().Add( 5*time.Second))
+    reader := bufio.NewReader( conn)
+    var lines []string
+    for len(lines)<4 {
+        line, err := reader.ReadString('\n')
+        if err!=nil {
+            t.Error(t.Name(),`ReadString() failed:`,err)
+            return
+        }
+        lines= append( lines, strings.TrimSpace( line))
+    }
+    expected := []string{"First message", "Second", "message", "Third message"}
+    for indx := range expected {
+        if lines[indx]!=expected[indx] {
+            t.Error(t.Name(),`Unexpected line: got:`,lines[indx],`expected:`,expected[indx])
+        }
+    }
+    logger.Flush()
+    if fileInfo, err := os.Stat( spillFilename); err!=nil || fileInfo.Size()!=0 {
+        t.Error(t.Name(),`The spill file is not empty:`,fileInfo,err)
+    }
+}
+
+//--------------------------------------------------------------------------------------------------
+func TestNetworkSinkDisconnectedDrops( t *testing.T) {
+    // Finds a free port, nobody listens on it.
+    listener, err := net.Listen("tcp", "127.0.0.1:0")
+    if err!=nil {
+        t.Error(t.Name(),`Listen() failed:`,err)
+        return
+    }
+    address := listener.Addr().String()
+    listener.Close()
+
+    logger := NewLogger()
+    defer logger.Terminate()
+    chErrors := make( chan error, 10)
+    logger.SetErrorHandler( func( sinkId MessageSinkId, err error) { chErrors <- err })
+    sinkId, err := logger.AddNetworkSinkWithOptions("tcp", address, InfoSeverity,
+                                     NetworkSinkOptions{ MinReconnectDelay: time.Hour,
+                                                         MaxReconnectDelay: time.Hour, })
+    if err!=nil {
+        t.Error(t.Name(),`AddNetworkSinkWithOptions() failed:`,err)
+        return
+    }
+    logger.Info("First message")
+    logger.Info("Second message")
+    if err := logger.Flush(); !errors.Is( err, ErrDroppedMessages) {
+        t.Error(t.Name(),`Flush() did not report the dropped messages:`,err)
+    }
+    stats, _ := logger.SinkStatistics( sinkId)
+    if stats.Dropped!=2 || logger.DroppedMessages()!=2 {
+        t.Error(t.Name(),`Unexpected dropped messages:`,stats.Dropped,logger.DroppedMessages())
+    }
+    // The failed connection, then the drops are reported once.
+    for _, expected := range []string{"failed while trying to connect", "messages are dropped"} {
+        select {
+            case err := <- chErrors:
+                if !strings.Contains( err.Error(), expected) {
+                    t.Error(t.Name(),`Unexpected error:`,err,`expected:`,expected)
+                }
+            case <- time.After( 5*time.Second):
+                t.Error(t.Name(),`The error was not reported:`,expected)
+        }
+    }
+    select {
+        case err := <- chErrors:
+            t.Error(t.Name(),`Unexpected error:`,err)
+        default:
+    }
+}
+
+//--------------------------------------------------------------------------------------------------
+func TestNetworkSinkOversizedDatagram( t *testing.T) {
+    dirPath, err := ioutil.TempDir("", "log_network_sink_test_")
+    if err!=nil {
+        t.Error(t.Name(),`TempDir() failed:`,err)
+        return
+    }
+    defer os.RemoveAll( dirPath)
+    listener, err := net.ListenPacket("udp", "127.0.0.1:0")
+    if err!=nil {
+        t.Error(t.Name(),`ListenPacket() failed:`,err)
+        return
+    }
+    defer listener.Close()
+    conn, err := net.Dial("udp", listener.LocalAddr().String())
+    if err!=nil {
+        t.Error(t.Name(),`Dial() failed:`,err)
+        return
+    }
+    spillFile, err := os.Create( filepath.Join( dirPath, "spill"))
+    if err!=nil {
+        t.Error(t.Name(),`Create() failed:`,err)
+        return
+    }
+    defer spillFile.Close()
+    ctx := &networkLogMessageSink{ network: "udp",
+                                   address: listener.LocalAddr().String(),
+                                   options: NetworkSinkOptions{ MaxSpillSize: defaultMaxSpillSize,
+                                                                WriteTimeout: defaultNetworkWriteTimeout, },
+                                   conn: conn,
+                                   spillFile: spillFile, }
+    defer conn.Close()
+    oversized := strings.Repeat("x", 70000)
+
+    // A message larger than a datagram is dropped, the connection is kept.
+    networkSinkOnNewStrLog( ctx, oversized)
+    networkSinkOnNewStrLog( ctx, "First message")
+    if ctx.conn==nil || ctx.spillSize!=0 {
+        t.Error(t.Name(),`The oversized message broke the connection:`,ctx.conn,ctx.spillSize)
+        return
+    }
+    // A spilled message larger than a datagram does not stop the replay.
+    networkSinkSpill( ctx, oversized)
+    networkSinkSpill( ctx, "Second message")
+    if err := networkSinkReplay( ctx); err!=nil || ctx.spillSize!=0 {
+        t.Error(t.Name(),`networkSinkReplay() failed:`,err,ctx.spillSize)
+    }
+
+    buffer := make([]byte, 100)
+    for _, expected := range []string{"First message", "Second message"} {
+        listener.SetReadDeadline( time.Now().Add( 5*time.Second))
+        n, _, err := listener.ReadFrom( buffer)
+        if err!=nil {
+            t.Error(t.Name(),`ReadFrom() failed:`,err)
+            return
+        }
+        if string(buffer[:n])!=expected {
+            t.Error(t.Name(),`Unexpected datagram: got:`,string(buffer[:n]),`expected:`,expected)
+        }
+    }
+}
@@ -0,0 +1,9 @@
+//go:build js
+// +build js
+
+package dmlog
+
+import "os"
+
+// The platform has no SIGHUP: HandleReopenSignal() handles only the signals it is given.
+var defaultReopenSignals []os.Signal
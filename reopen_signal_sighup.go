@@ -0,0 +1,10 @@
+//go:build !js
+// +build !js
+
+package dmlog
+
+import "os"
+import "syscall"
+
+// The signals handled by HandleReopenSignal() when none is given.
+var defaultReopenSignals = []os.Signal{ syscall.SIGHUP}
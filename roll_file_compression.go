@@ -0,0 +1,148 @@
+package dmlog
+
+import "compress/gzip"
+import "fmt"
+import "io"
+import "os"
+import "path/filepath"
+import "strings"
+
+// Support for the compression of the files closed by the rolling file sink.
+
+// How the rolling file sink compresses the files it closes.
+type RollCompression int8
+
+// The supported compressions.
+const (
+    NoCompression RollCompression = iota
+    GzipCompression // The closed file is replaced by a gzip file, with the .gz extension added.
+)
+
+// The extension added to the gzip compressed files.
+const gzipExtension string = ".gz"
+
+// The extension of a compressed file while it is being written.
+const compressingExtension string = ".tmp"
+
+// Implements the Stringable interface
+func (c RollCompression) String() string {
+    switch c {
+        case NoCompression:   return "none"
+        case GzipCompression: return "gzip"
+    }
+    return "Unknown"
+}
+
+//--------------------------------------------------------------------------------------------------
+/* Retrieves the log files in the directory dirPath having the given prefix, compressed or not.
+   Files whose compression is in progress are counted once, and the partially written compressed
+   files are excluded. */
+func listRollFiles( dirPath string, filePrefix string) ([]os.FileInfo, error) {
+    matchFiles, err := filepath.Glob( filepath.Join( dirPath, filePrefix+ "*"))
+    if err!=nil {
+        return nil, fmt.Errorf("filepath.Glob() failed:%s",err)
+    }
+    isMatching := make( map[string]bool, len(matchFiles))
+    for _, matchFile := range matchFiles {
+        isMatching[matchFile]= true
+    }
+    result := make( []os.FileInfo, 0, len(matchFiles)) 
+    for _, matchFile := range matchFiles {
+        if strings.HasSuffix( matchFile, compressingExtension) || isMatching[matchFile+ gzipExtension] {
+            continue
+        }
+        fileInfo, err := os.Stat( matchFile)
+        if err!=nil {
+            if os.IsNotExist( err) {
+                continue
+            }
+            return nil, fmt.Errorf("os.Stat() failed on %s",matchFile)
+        }
+        result= append( result, fileInfo)
+    }
+    return result, nil
+}
+
+//--------------------------------------------------------------------------------------------------
+/* Removes a log file, if it still exists.  When it is a compressed file, the uncompressed file it
+   was created from is removed as well, if it still exists. */
+func removeRollFile( dirPath string, filename string) error {
+    fullPath := filepath.Join( dirPath, filename)
+    if err := os.Remove( fullPath); err!=nil && !os.IsNotExist( err) {
+        return fmt.Errorf("failed while trying to remove the file %s:%s",filename,err)
+    }
+    if strings.HasSuffix( fullPath, gzipExtension) {
+        err := os.Remove( strings.TrimSuffix( fullPath, gzipExtension))
+        if err!=nil && !os.IsNotExist( err) {
+            return fmt.Errorf("failed while trying to remove the file %s:%s",filename,err)
+        }
+    }
+    return nil
+}
+
+//--------------------------------------------------------------------------------------------------
+/* Compresses the file filePath into filePath.gz, that gets the same modification time, then 
+   removes filePath.  The compressed file is written under a temporary name and renamed only 
+   when complete. */
+func gzipFile( filePath string) error {
+    srcFile, err := os.Open( filePath)
+    if err!=nil {
+        return fmt.Errorf("failed while trying to open the file %s:%s",filePath,err)
+    }
+    defer srcFile.Close()
+    srcInfo, err := srcFile.Stat()
+    if err!=nil {
+        return fmt.Errorf("failed while trying to stat the file %s:%s",filePath,err)
+    }
+
+    dstPath := filePath+ gzipExtension
+    tmpPath := dstPath+ compressingExtension
+    dstFile, err := os.Create( tmpPath)
+    if err!=nil {
+        return fmt.Errorf("failed while trying to create the file %s:%s",tmpPath,err)
+    }
+    writer := gzip.NewWriter( dstFile)
+    writer.Name= filepath.Base( filePath)
+    writer.ModTime= srcInfo.ModTime()
+    _, err = io.Copy( writer, srcFile)
+    if err==nil {
+        err= writer.Close()
+    }
+    if closeErr := dstFile.Close(); err==nil {
+        err= closeErr
+    }
+    if err!=nil {
+        os.Remove( tmpPath)
+        return fmt.Errorf("failed while trying to compress the file %s:%s",filePath,err)
+    }
+
+    // The source file can be removed by the retention policy in the meanwhile.
+    if _, err = os.Stat( filePath); os.IsNotExist( err) {
+        os.Remove( tmpPath)
+        return nil
+    }
+    if err = os.Chtimes( tmpPath, srcInfo.ModTime(), srcInfo.ModTime()); err!=nil {
+        os.Remove( tmpPath)
+        return fmt.Errorf("failed while trying to set the time of the file %s:%s",tmpPath,err)
+    }
+    if err = os.Rename( tmpPath, dstPath); err!=nil {
+        os.Remove( tmpPath)
+        return fmt.Errorf("failed while trying to rename the file %s:%s",tmpPath,err)
+    }
+    return os.Remove( filePath)
+}
+
+//--------------------------------------------------------------------------------------------------
+// Compresses the closed file in the background, according to the sink options.
+func rollFileSinkCompress( ctx *rollFileLogMessageSink, filePath string) {
+    if ctx.options.Compression != GzipCompression {
+        return
+    }
+    ctx.wgCompression.Add(1)
+    go func() {
+        defer ctx.wgCompression.Done()
+        if err := gzipFile( filePath); err!=nil {
+            ctx.ReportError( err)
+        }
+    }()
+}
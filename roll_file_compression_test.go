@@ -0,0 +1,110 @@
+package dmlog
+
+import "compress/gzip"
+import "io/ioutil"
+import "os"
+import "path/filepath"
+import "strings"
+import "testing"
+import "time"
+
+//--------------------------------------------------------------------------------------------------
+func TestRollFileSinkGzip( t *testing.T) {
+    tempDirName, err := ioutil.TempDir("", "roll_file_sink_test")
+    if err != nil {
+        t.Error(t.Name(),`TempDir() failed:`,err)            
+        return
+    }
+    defer os.RemoveAll( tempDirName)
+    filePrefix := "roll_file"
+    currTime := time.Date(2021, time.January, 6, 22, 29, 40, 0, time.UTC)
+    now := func() time.Time { return currTime }
+    options := RollFileSinkOptions{ Period: DailyRollPeriod, Location: time.UTC, Compression: GzipCompression}
+    sink, err := newRollFileLogMessageSink( tempDirName, filePrefix, 10, 0, DebugSeverity, options, now)
+    if err != nil {
+        t.Error(t.Name(),`newRollFileLogMessageSink() failed:`,err)            
+        return
+    }
+    logger := NewLogger()
+    if _, err = logger.AddSink( sink); err!=nil {
+        t.Error(t.Name(),`AddSink() failed:`,err)            
+        return
+    }
+    logger.Debug("First day")
+    logger.Flush()
+    currTime= currTime.Add( 2*time.Hour)
+    logger.Debug("Second day")
+    logger.Terminate()
+
+    want := []string{ filePrefix+"_20210106_222940_000.txt.gz", filePrefix+"_20210107_002940_000.txt.gz" }
+    writtenFiles, err := filepath.Glob( filepath.Join(tempDirName, filePrefix+"*") )
+    if err != nil {
+        t.Error(t.Name(),`filepath.Glob() failed:`,err)            
+        return
+    }
+    if len(writtenFiles)!=len(want) {
+        t.Error(t.Name(),`got files`,writtenFiles,`want`,want)            
+        return
+    }
+    for indx, writtenFile := range writtenFiles {
+        if filepath.Base(writtenFile)!=want[indx] {
+            t.Error(t.Name(),`got file`,writtenFile,`want`,want[indx])            
+            continue
+        }
+        content, err := readGzipFile( writtenFile)
+        if err!=nil {
+            t.Error(t.Name(),`readGzipFile() failed:`,err)
+        } else if !strings.Contains( content, []string{"First day","Second day"}[indx]) {
+            t.Error(t.Name(),`unexpected content of`,writtenFile,`:`,content)
+        }
+    }
+}
+
+//--------------------------------------------------------------------------------------------------
+func TestListRollFiles( t *testing.T) {
+    tempDirName, err := ioutil.TempDir("", "roll_file_sink_test")
+    if err != nil {
+        t.Error(t.Name(),`TempDir() failed:`,err)            
+        return
+    }
+    defer os.RemoveAll( tempDirName)
+    filenames := []string{ "log_1.txt", "log_1.txt.gz",   // Compression just completed.
+                           "log_2.txt", "log_2.txt.gz.tmp", // Compression in progress.
+                           "log_3.txt.gz", 
+                           "log_4.txt",
+                           "other.txt" }
+    for _, filename := range filenames {
+        if err := ioutil.WriteFile( filepath.Join( tempDirName, filename), []byte("x"), 0600); err!=nil {
+            t.Error(t.Name(),`WriteFile() failed:`,err)            
+            return
+        }
+    }
+    fileInfos, err := listRollFiles( tempDirName, "log")
+    if err!=nil {
+        t.Error(t.Name(),`listRollFiles() failed:`,err)            
+        return
+    }
+    got := make( []string, 0, len(fileInfos))
+    for _, fileInfo := range fileInfos {
+        got= append( got, fileInfo.Name())
+    }
+    want := []string{ "log_1.txt.gz", "log_2.txt", "log_3.txt.gz", "log_4.txt" }
+    if strings.Join( got, ",")!=strings.Join( want, ",") {
+        t.Error(t.Name(),`got`,got,`want`,want)            
+    }
+}
+
+// Retrieves the uncompressed content of a gzip file.
+func readGzipFile( filePath string) (string, error) {
+    file, err := os.Open( filePath)
+    if err!=nil {
+        return "", err
+    }
+    defer file.Close()
+    reader, err := gzip.NewReader( file)
+    if err!=nil {
+        return "", err
+    }
+    content, err := ioutil.ReadAll( reader)
+    return string(content), err
+}
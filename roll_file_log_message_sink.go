@@ -1,21 +1,20 @@
 package dmlog
 
 import "fmt"
-import "log"
 import "os"
-import "path"
 import "path/filepath"
-import "sort"
 import "strings"
+import "sync"
 import "time"
 
-const defaultCapChStrLog int = 100
-
 // The extension of the log file
 const fileExtension string = ".txt"
 
 const timestampSeparator string = "_"
 
+// The digits of the counter appended to the name of a file created in the same millisecond.
+const rollCounterDigits int = 3
+
 type Bytes  uint64
 type KBytes uint64
 const kBytesToBytes Bytes = 1024
@@ -26,19 +25,58 @@ type rollFileLogMessageSink struct {
     
     dirPath           string
     filePrefix        string
-    numMaxFiles       int
+    retention         rollRetention
     maxFileSize       Bytes
     currFile          *os.File
     currFileSize      int
+    options           RollFileSinkOptions
+    // When the current file must be rolled; zero if there is no time based rotation.
+    rollTime          time.Time
+    // Retrieves the current time.
+    now               func() time.Time
+    // Tracks the compressions running in the background.
+    wgCompression     sync.WaitGroup
+}
+
+/* Optional settings of the rolling file sink. 
+   The zero value keeps the size based rotation only. */
+type RollFileSinkOptions struct {
+    // The period of the time based rotation, combined with the size limit if any.
+    Period RollPeriod
+
+    /* When the rotation happens within the period.  For the daily and weekly periods it is the 
+       time of day, for the hourly period only its minutes and seconds are used. */
+    TimeOfDay time.Duration
+
+    // The day of the week of the rotation, for the weekly period.
+    Weekday time.Weekday
+
+    // The time zone of the rotation; when nil, the local time zone is used.
+    Location *time.Location
+
+    /* How the closed files are compressed, in the background.  Compressed files are counted by
+       the retention policy as the other files. */
+    Compression RollCompression
 
-    chStrLog          chan string
-    chReqTerminate    chan struct{} 
-    chReplyTerminate  chan struct{} 
+    // Files older than MaxAge are deleted; zero means no age limit.
+    MaxAge time.Duration
+
+    /* The oldest files are deleted so that the total size of the files having the prefix is at 
+       most MaxTotalSize; zero means no limit.  Room for a file of maxFileSize is kept for the
+       current file, therefore MaxTotalSize cannot be less than maxFileSize.  When maxFileSize is
+       zero, i.e. the files are rolled only by period, the size of the current file is not 
+       limited and the limit applies to the closed files only. */
+    MaxTotalSize KBytes
+
+    /* The oldest files are deleted as long as the free space of the disk is less than 
+       MinFreeDiskSpace; zero disables the check.  Not supported on all platforms. */
+    MinFreeDiskSpace KBytes
 }
 
 /* Adds a trace message sink that write messages into log files stored in the directory dirPath.
    The created files have the given filePrefix.
    At most numMaxFiles matching the dirPath and the filePrefix are kept, then the oldest is erased.
+   The limit is enforced when the sink is created and every time a new file is started.
    Moreover, each log file size is at most maxFileSize, expressed into kBytes.
    In case error is nil, the returned message sink id can be used later to modify the severity 
    threshold.*/
@@ -47,11 +85,44 @@ func AddRollFileSink( dirPath         string,
                       numMaxFiles     int,
                       maxFileSize     KBytes, 
                       threshold       LogSeverity) (MessageSinkId, error) {
-    msgSink, err := newRollFileLogMessageSink(dirPath, filePrefix, numMaxFiles, maxFileSize, threshold)
+    return DefaultLogger().AddRollFileSink( dirPath, filePrefix, numMaxFiles, maxFileSize, threshold)
+}
+
+// Adds to the logger a sink that writes messages into rolling log files, see AddRollFileSink().
+func (l *Logger) AddRollFileSink( dirPath         string,
+                                  filePrefix      string,
+                                  numMaxFiles     int,
+                                  maxFileSize     KBytes, 
+                                  threshold       LogSeverity) (MessageSinkId, error) {
+    return l.AddRollFileSinkWithOptions( dirPath, filePrefix, numMaxFiles, maxFileSize, threshold, 
+                                         RollFileSinkOptions{})
+}
+
+/* Same as AddRollFileSink(), with the optional settings, e.g. to start a new file every day.
+   When a roll period is set, maxFileSize can be zero, meaning that the file size is unlimited.*/
+func AddRollFileSinkWithOptions( dirPath         string,
+                                 filePrefix      string,
+                                 numMaxFiles     int,
+                                 maxFileSize     KBytes, 
+                                 threshold       LogSeverity,
+                                 options         RollFileSinkOptions) (MessageSinkId, error) {
+    return DefaultLogger().AddRollFileSinkWithOptions( dirPath, filePrefix, numMaxFiles, maxFileSize, 
+                                                     threshold, options)
+}
+
+// Adds to the logger a rolling file sink with the optional settings, see AddRollFileSinkWithOptions().
+func (l *Logger) AddRollFileSinkWithOptions( dirPath         string,
+                                             filePrefix      string,
+                                             numMaxFiles     int,
+                                             maxFileSize     KBytes, 
+                                             threshold       LogSeverity,
+                                             options         RollFileSinkOptions) (MessageSinkId, error) {
+    msgSink, err := newRollFileLogMessageSink(dirPath, filePrefix, numMaxFiles, maxFileSize, threshold,
+                                              options, time.Now)
     if err!=nil {
         return MessageSinkId(0), err
     } 
-    return addMessageSink( msgSink)
+    return l.addMessageSink( msgSink)
 }
 
 type fileInfoSliceType []os.FileInfo
@@ -60,7 +131,11 @@ func (f fileInfoSliceType) Len() int {
     return len(f)
 }
 
+// Files with the same modification time are ordered by name, i.e. by creation.
 func (f fileInfoSliceType) Less(i, j int) bool {
+    if f[i].ModTime().Equal(f[j].ModTime()) {
+        return f[i].Name() < f[j].Name()
+    }
     return f[i].ModTime().Before(f[j].ModTime())
 }
 
@@ -73,7 +148,9 @@ func newRollFileLogMessageSink( dirPath       string,
                                 filePrefix    string,
                                 numMaxFiles   int,
                                 maxFileSize   KBytes, 
-                                threshold     LogSeverity) (*rollFileLogMessageSink, error) {
+                                threshold     LogSeverity,
+                                options       RollFileSinkOptions,
+                                now           func() time.Time) (*rollFileLogMessageSink, error) {
     // Evaluates dirPath
     dirPathInfo, err := os.Stat( dirPath)
     if err!=nil {
@@ -92,11 +169,25 @@ func newRollFileLogMessageSink( dirPath       string,
         return nil,fmt.Errorf("invalid numMaxFiles parameter %d",numMaxFiles)
     }
 
-    if maxFileSize<=0 {
+    if err = validateRollPeriod( &options); err!=nil {
+        return nil,err
+    }
+
+    if options.Compression!=NoCompression && options.Compression!=GzipCompression {
+        return nil,fmt.Errorf("invalid compression %d",options.Compression)
+    }
+
+    if err = validateRollRetention( maxFileSize, &options); err!=nil {
+        return nil,err
+    }
+
+    if maxFileSize<=0 && options.Period==NoRollPeriod {
         return nil,fmt.Errorf("invalid maxFileSize parameter %d",maxFileSize)
     }
         
-    logFile, err := createNewRollFile( trimmedFilePrefix, dirPath,  numMaxFiles) 
+    currTime := now()
+    retention := newRollRetention( numMaxFiles, maxFileSize, &options)
+    logFile, err := createNewRollFile( trimmedFilePrefix, dirPath, &retention, currTime) 
     if err!=nil {
         return nil,err
     }
@@ -114,15 +205,14 @@ func newRollFileLogMessageSink( dirPath       string,
                         },  
                         dirPath: dirPath,
                         filePrefix: trimmedFilePrefix,
-                        numMaxFiles: numMaxFiles,
+                        retention: retention,
                         maxFileSize: Bytes( maxFileSize)*kBytesToBytes,
                         currFile: logFile,
                         currFileSize:0,
-                        chStrLog: make( chan string, defaultCapChStrLog),
-                        chReqTerminate: make( chan struct{}),
-                        chReplyTerminate: make( chan struct{}),
+                        options: options,
+                        rollTime: nextRollTime( currTime, &options),
+                        now: now,
                     }
-    go rollFileSinkHandler( &result)
     return &result,nil
 }
 
@@ -139,17 +229,27 @@ func (c *rollFileLogMessageSink) Severity() LogSeverity {
 //--------------------------------------------------------------------------------------------------
 func (r *rollFileLogMessageSink) OnLogMessage( msg *LogMessage) {
     if msg.severity.IsGreaterOrEqualThan( r.threshold) {
-        format, ok := r.messageTypeToFormat[ msg.messageType ]
-        if ok {
-            r.chStrLog <- formatLogMessage( msg, &format)
-        } else {
-            r.chStrLog <- msg.text
+        rollFileSinkOnNewStrLog( r, r.FormatMessage( msg))
+        if r.isFrequentFlush {
+            r.Flush()
         }
     }
 }
     
 //--------------------------------------------------------------------------------------------------
-func (r *rollFileLogMessageSink) flush() {}
+// Same as FlushError(), the error is discarded.
+func (r *rollFileLogMessageSink) Flush() {
+    r.FlushError()
+}
+
+//--------------------------------------------------------------------------------------------------
+// Syncs the current file.
+func (r *rollFileLogMessageSink) FlushError() error {
+    if nil!=r.currFile {
+        return r.currFile.Sync()
+    }
+    return nil
+}
     
 //--------------------------------------------------------------------------------------------------
 func (r *rollFileLogMessageSink) SetFlush( isFrequentFlush bool) {
@@ -157,132 +257,113 @@ func (r *rollFileLogMessageSink) SetFlush( isFrequentFlush bool) {
 }
 
 //--------------------------------------------------------------------------------------------------
-func (r *rollFileLogMessageSink) setSinkFormat( messageType MessageType, 
+func (r *rollFileLogMessageSink) SetSinkFormat( messageType MessageType, 
                                                   format LogFormatItems) bool {
-    return r.BaseLogMessageSink.setSinkFormat( messageType, format)
+    return r.BaseLogMessageSink.SetSinkFormat( messageType, format)
 }
     
 //--------------------------------------------------------------------------------------------------
-func (r *rollFileLogMessageSink) terminate() {
-    close( r.chReqTerminate)
-    <- r.chReplyTerminate 
+// Closes and compresses the current file, then waits for the compressions to complete.
+func (r *rollFileLogMessageSink) Terminate() {
+    if nil!=r.currFile {
+        if err := r.currFile.Close(); err!=nil {
+            r.ReportError( fmt.Errorf("failed while trying to close the file %s:%w",r.currFile.Name(),err))
+        }
+        rollFileSinkCompress( r, r.currFile.Name())
+        r.currFile = nil 
+    }
+    r.wgCompression.Wait()
 }
 
 //--------------------------------------------------------------------------------------------------
-// Retrieves the number of files in the directory dirPath, that match the fileGlob pattern.
-func numFilesMatching(dirPath string, fileGlob string) (int, error) {
-    filePattern := path.Join( dirPath, fileGlob)
-    matchFiles, err := filepath.Glob( filePattern)
-    if err!=nil {
-        return 0, fmt.Errorf("Glob() failed:%s",err)
+func createNewRollFile( filePrefix string, 
+                        dirPath string, 
+                        retention *rollRetention, 
+                        now time.Time) (*os.File,error) {
+    if err := enforceRollRetention( dirPath, filePrefix, retention, now); err!=nil {
+        return nil,fmt.Errorf("enforceRollRetention() failed: %s",err)
     }
-    return len(matchFiles),nil
+    return createNewFile( dirPath, filePrefix, fileExtension, now)
 }
 
 //--------------------------------------------------------------------------------------------------
-/* Deletes the numOlderFiles oldest files matching the pattern numOlderFiles in the 
- * directory dirPath */
-func deleteOlderFiles( dirPath string, fileGlob string, numOlderFiles int ) error {
-    filePattern := filepath.Join( dirPath, fileGlob)
-    matchFiles, err := filepath.Glob( filePattern)
-    if err!=nil {
-        return fmt.Errorf("filepath.Glob() failed:%s",err)
-    }
-    numMatchingFiles := len(matchFiles)
-    fileInfoSlice := make( []os.FileInfo, 0, numMatchingFiles) 
-    for _, matchFile := range matchFiles {
-        fileInfo, err := os.Stat( matchFile)
-        if err!=nil {
-            return fmt.Errorf("os.Stat() failed on %s",matchFile)
-        } else {
-            fileInfoSlice= append( fileInfoSlice, fileInfo)
+/* Creates a file named after the prefix and the timestamp.  An existing file is never truncated:
+   when two files are created in the same millisecond, a counter is appended to the name of the 
+   second one, e.g. roll_file_20210106_222940_834_001.txt.  The counter is zero padded, so that
+   the names sort in creation order when the modification times are equal. */
+func createNewFile( dirPath string, 
+                    filePrefix string, 
+                    fileExtension string, 
+                    now time.Time) (*os.File,error) {
+    var basename = filePrefix+ 
+                   timestampSeparator+ 
+                   timestampString( now, timestampSeparator)
+    for counter:=0; ; counter++ {
+        filename := basename+ fileExtension
+        if counter>0 {
+            filename= basename+ timestampSeparator+ fmt.Sprintf("%0*d", rollCounterDigits, counter)+
+                      fileExtension
         }
-    }
-    if numMatchingFiles<numOlderFiles {
-        log.Println(numMatchingFiles,"files: no file to delete.")
-    } else {
-        sort.Sort(sort.Reverse( fileInfoSliceType(fileInfoSlice) ))
-        for indx:=0; indx<numOlderFiles; indx++ {
-          filename :=  fileInfoSlice[indx].Name()
-          err := os.Remove( filepath.Join( dirPath, fileInfoSlice[indx].Name()) )
-          if err!=nil {
-              return fmt.Errorf("failed while trying to remove the file %s:%s",filename,err)
-          } 
+        file, err := os.OpenFile( filepath.Join( dirPath, filename), 
+                                  os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0666)
+        if err==nil || !os.IsExist( err) {
+            return file, err
         }
     }
-
-    return nil 
 }
-//--------------------------------------------------------------------------------------------------
-func createNewRollFile( filePrefix string, dirPath string, numMaxFiles int) (*os.File,error) {
 
-    logFilesGlob := filePrefix+ "*"
-    numFiles, err := numFilesMatching( dirPath, logFilesGlob)
-    if err!=nil {
-        return nil,fmt.Errorf("numFilesMatching() failed: %s",err)
+//--------------------------------------------------------------------------------------------------
+func rollFileSinkOnNewStrLog( ctx *rollFileLogMessageSink, strMessage string) {
+    var strMessageLen = len(strMessage)
+    now := ctx.now()
+    isRollTime := !ctx.rollTime.IsZero() && !now.Before( ctx.rollTime)
+    isSizeAvailable := (ctx.maxFileSize==0) || 
+                       ( Bytes(ctx.currFileSize + strMessageLen) < ctx.maxFileSize )
+    if (ctx.currFile == nil ) || !isSizeAvailable || isRollTime {
+        rollFileSinkRoll( ctx, now)
     }
-    if numFiles >= numMaxFiles {
-        err = deleteOlderFiles( dirPath, logFilesGlob, numFiles - numMaxFiles + 1)
-        return nil,err
+    if ctx.currFile == nil {
+        ctx.ReportError( fmt.Errorf("message dropped, there is no log file in %s",ctx.dirPath))
+        return
     }
-
-    return createNewFile( dirPath, filePrefix, fileExtension)
-}
-
-//--------------------------------------------------------------------------------------------------
-func createNewFile( dirPath string, filePrefix string, fileExtension string) (*os.File,error) {
-    var filename = filePrefix+ 
-                   timestampSeparator+ 
-                   timestampString( time.Now(), timestampSeparator)+
-                   fileExtension 
-    var filepath = filepath.Join(dirPath, filename)
-    return os.Create( filepath)
+    if _, err := fmt.Fprint( ctx.currFile, strMessage); err!=nil {
+        ctx.ReportError( fmt.Errorf("failed while trying to write to the file %s:%w",
+                                    ctx.currFile.Name(),err))
+    }
+    ctx.currFileSize += strMessageLen
 }
 
 //--------------------------------------------------------------------------------------------------
-func rollFileSinkHandler( ctx *rollFileLogMessageSink) {
-    for terminate:= false; !terminate; {
-        select {
-            case strLog := <- ctx.chStrLog: {
-                rollFileSinkOnNewStrLog( ctx, strLog)                
-            }
-            case <- ctx.chReqTerminate: {
-                for hasLogStrings:= true; hasLogStrings; {
-                    select {
-                        case strLog := <- ctx.chStrLog: {
-                            rollFileSinkOnNewStrLog( ctx, strLog)                
-                        }
-                        default: 
-                            hasLogStrings = false                      
-                    }                       
-                }
-                if nil!=ctx.currFile {
-                    ctx.currFile.Close()
-                    ctx.currFile = nil 
-                }
-                terminate= true                
-            }
+/* Closes the current file and starts a new one.  If the new file cannot be created, the previous
+   one is opened again, so that the messages are not lost; the roll is tried again with the next
+   message. */
+func rollFileSinkRoll( ctx *rollFileLogMessageSink, now time.Time) {
+    var prevFilePath string
+    if ctx.currFile != nil {
+        prevFilePath = ctx.currFile.Name()
+        if err := ctx.currFile.Close(); err!=nil {
+            ctx.ReportError( fmt.Errorf("failed while trying to close the file %s:%w",prevFilePath,err))
         }
+        ctx.currFile = nil
     }
-    close( ctx.chReplyTerminate)
-}
-
-//--------------------------------------------------------------------------------------------------
-func rollFileSinkOnNewStrLog( ctx *rollFileLogMessageSink, strMessage string) {
-    var strMessageLen = len(strMessage)
-    if (ctx.currFile != nil ) &&
-       ( Bytes(ctx.currFileSize + strMessageLen) < ctx.maxFileSize ) {
-        fmt.Fprint( ctx.currFile, strMessage)
-        ctx.currFileSize += strMessageLen
-    } else {
-        if ctx.currFile != nil {
-            ctx.currFile.Close()
+    newFile, err := createNewRollFile( ctx.filePrefix, ctx.dirPath, &ctx.retention, now) 
+    if err!=nil {
+        ctx.ReportError( fmt.Errorf("failed while trying to create a new log file in %s:%w",
+                                    ctx.dirPath,err))
+        if len(prevFilePath)>0 {
+            prevFile, err := openLogFile( prevFilePath, true)
+            if err!=nil {
+                ctx.ReportError( err)
+                return
+            }
+            ctx.currFile = prevFile
         }
-        newFile, err := createNewRollFile( ctx.filePrefix, ctx.dirPath, ctx.numMaxFiles) 
-        if err==nil {
-            ctx.currFile = newFile
-            fmt.Fprint( ctx.currFile, strMessage)
-            ctx.currFileSize = strMessageLen         
-        }                    
-    }             
+        return
+    }
+    if len(prevFilePath)>0 {
+        rollFileSinkCompress( ctx, prevFilePath)
+    }
+    ctx.currFile = newFile
+    ctx.rollTime = nextRollTime( now, &ctx.options)
+    ctx.currFileSize = 0
 }
@@ -5,6 +5,8 @@ import "log"
 import "path"
 import "path/filepath"
 import "os"
+import "sort"
+import "strings"
 import "testing"
 import "time"
 
@@ -30,13 +32,17 @@ func TestRollFileSink( t *testing.T) {
     Info("Info message ",time.Now())
     Print("Print message ",time.Now())
 
+    // Each message is longer than 400 bytes, so that more than numMaxFiles files are written.
+    padding := strings.Repeat(".", 400)
     for indx:=0; indx<100; indx++ {
         for j:=0; j<10; j++ {
-            Debug("Roll sink test:",time.Now()," message #", indx+j+1,".................................")
+            Debug("Roll sink test:",time.Now()," message #", indx+j+1,padding)
         }
         time.Sleep( 1*time.Millisecond)
     }
-    time.Sleep( 1*time.Second)
+    if err := Flush(); err!=nil {
+        t.Error(t.Name(),`Flush() failed:`,err)            
+    }
     
     ClearSinks()
 
@@ -51,6 +57,82 @@ func TestRollFileSink( t *testing.T) {
     }
 }
 
+//--------------------------------------------------------------------------------------------------
+func TestRollFileSinkDaily( t *testing.T) {
+    tempDirName, err := ioutil.TempDir("", "roll_file_sink_test")
+    if err != nil {
+        t.Error(t.Name(),`TempDir() failed:`,err)            
+        return
+    }
+    defer os.RemoveAll( tempDirName)
+    filePrefix := "roll_file"
+    currTime := time.Date(2021, time.January, 6, 22, 29, 40, 0, time.UTC)
+    now := func() time.Time { return currTime }
+    options := RollFileSinkOptions{ Period: DailyRollPeriod, Location: time.UTC}
+    sink, err := newRollFileLogMessageSink( tempDirName, filePrefix, 10, 0, DebugSeverity, options, now)
+    if err != nil {
+        t.Error(t.Name(),`newRollFileLogMessageSink() failed:`,err)            
+        return
+    }
+    logger := NewLogger()
+    defer logger.Terminate()
+    if _, err = logger.AddSink( sink); err!=nil {
+        t.Error(t.Name(),`AddSink() failed:`,err)            
+        return
+    }
+    logger.Debug("First day")
+    logger.Flush()
+    currTime= currTime.Add( 2*time.Hour)
+    logger.Debug("Second day")
+    logger.Debug("Second day, again")
+    logger.Flush()
+    logger.ClearSinks()
+
+    writtenFiles, err := filepath.Glob( path.Join(tempDirName, filePrefix+"*") )
+    if err != nil {
+        t.Error(t.Name(),`filepath.Glob() failed:`,err)            
+        return
+    }
+    want := []string{ filePrefix+"_20210106_222940_000.txt", filePrefix+"_20210107_002940_000.txt" }
+    if len(writtenFiles)!=len(want) || 
+       filepath.Base(writtenFiles[0])!=want[0] || filepath.Base(writtenFiles[1])!=want[1] {
+        t.Error(t.Name(),`got files`,writtenFiles,`want`,want)            
+    }
+}
+
+//--------------------------------------------------------------------------------------------------
+func TestCreateNewFileSameTime( t *testing.T) {
+    tempDirName, err := ioutil.TempDir("", "roll_file_sink_test")
+    if err != nil {
+        t.Error(t.Name(),`TempDir() failed:`,err)            
+        return
+    }
+    defer os.RemoveAll( tempDirName)
+    now := time.Date(2021, time.January, 6, 22, 29, 40, 834000000, time.UTC)
+    var names []string
+    for indx:=0; indx<12; indx++ {
+        file, err := createNewFile( tempDirName, "roll_file", fileExtension, now)
+        if err != nil {
+            t.Error(t.Name(),`createNewFile() failed:`,err)            
+            return
+        }
+        file.WriteString("content")
+        file.Close()
+        names= append( names, filepath.Base( file.Name()))
+    }
+    want := []string{ "roll_file_20210106_222940_834.txt", "roll_file_20210106_222940_834_001.txt" }
+    if names[0]!=want[0] || names[1]!=want[1] || names[11]!="roll_file_20210106_222940_834_011.txt" {
+        t.Error(t.Name(),`got files`,names,`want`,want)            
+    }
+    // With the same modification time, the files sort in creation order.
+    if !sort.StringsAreSorted( names) {
+        t.Error(t.Name(),`The files do not sort in creation order:`,names)            
+    }
+    if content, err := ioutil.ReadFile( filepath.Join( tempDirName, want[0])); err!=nil || string(content)!="content" {
+        t.Error(t.Name(),`The first file was truncated:`,string(content),err)            
+    }
+}
+
 func ExampleAddRollFileSink() {
     filePrefix := "roll_file"
     const numMaxFiles = 3
@@ -0,0 +1,103 @@
+package dmlog
+
+import "fmt"
+import "sort"
+import "time"
+
+// Support for the retention policies of the files written by the rolling file sink.
+
+/* The limits on the log files kept by a rolling file sink.  Zero values disable a limit.
+   maxFileSize is the size reserved for the file to be created within maxTotalSize. */
+type rollRetention struct {
+    numMaxFiles       int
+    maxAge            time.Duration
+    maxTotalSize      Bytes
+    minFreeDiskSpace  Bytes
+    maxFileSize       Bytes
+}
+
+// Creates the retention limits of a rolling file sink.
+func newRollRetention( numMaxFiles int, 
+                       maxFileSize KBytes, 
+                       options *RollFileSinkOptions) rollRetention {
+    return rollRetention{ numMaxFiles: numMaxFiles,
+                          maxFileSize: Bytes( maxFileSize)*kBytesToBytes,
+                          maxAge: options.MaxAge,
+                          maxTotalSize: Bytes( options.MaxTotalSize)*kBytesToBytes,
+                          minFreeDiskSpace: Bytes( options.MinFreeDiskSpace)*kBytesToBytes, }
+}
+
+// Checks that the retention settings of the options are valid.
+func validateRollRetention( maxFileSize KBytes, options *RollFileSinkOptions) error {
+    if options.MaxAge<0 {
+        return fmt.Errorf("invalid max age %s", options.MaxAge)
+    }
+    if options.MaxTotalSize>0 && maxFileSize>options.MaxTotalSize {
+        return fmt.Errorf("max total size %d less than the max file size %d", 
+                          options.MaxTotalSize, maxFileSize)
+    }
+    if options.MinFreeDiskSpace>0 && !isDiskFreeSpaceSupported {
+        return fmt.Errorf("min free disk space not supported on this platform")
+    }
+    return nil
+}
+
+//--------------------------------------------------------------------------------------------------
+/* Deletes the oldest log files having the given prefix in the directory dirPath, so that a new
+   file can be created within the limits:
+   - at most numMaxFiles-1 files are kept;
+   - the files older than maxAge are deleted;
+   - the total size of the kept files plus maxFileSize, reserved for the new file, is at most
+     maxTotalSize;
+   - old files are deleted as long as the free disk space is less than minFreeDiskSpace. */
+func enforceRollRetention( dirPath string, 
+                           filePrefix string, 
+                           retention *rollRetention, 
+                           now time.Time) error {
+    fileInfoSlice, err := listRollFiles( dirPath, filePrefix)
+    if err!=nil {
+        return err
+    }
+    sort.Sort( fileInfoSliceType(fileInfoSlice) )
+    numFiles := len(fileInfoSlice)
+
+    numOlderFiles := 0
+    if retention.numMaxFiles>0 && numFiles>=retention.numMaxFiles {
+        numOlderFiles= numFiles - retention.numMaxFiles + 1
+    }
+    if retention.maxAge>0 {
+        for numOlderFiles<numFiles && now.Sub( fileInfoSlice[numOlderFiles].ModTime())>retention.maxAge {
+            numOlderFiles++
+        }
+    }
+    if retention.maxTotalSize>0 {
+        totalSize := retention.maxFileSize
+        for _, fileInfo := range fileInfoSlice[numOlderFiles:] {
+            totalSize+= Bytes( fileInfo.Size())
+        }
+        for numOlderFiles<numFiles && totalSize>retention.maxTotalSize {
+            totalSize-= Bytes( fileInfoSlice[numOlderFiles].Size())
+            numOlderFiles++
+        }
+    }
+    if retention.minFreeDiskSpace>0 {
+        freeSpace, err := diskFreeSpace( dirPath)
+        if err!=nil {
+            return fmt.Errorf("diskFreeSpace() failed on %s:%s",dirPath,err)
+        }
+        for indx:=0; indx<numOlderFiles; indx++ {
+            freeSpace+= Bytes( fileInfoSlice[indx].Size())
+        }
+        for numOlderFiles<numFiles && freeSpace<retention.minFreeDiskSpace {
+            freeSpace+= Bytes( fileInfoSlice[numOlderFiles].Size())
+            numOlderFiles++
+        }
+    }
+
+    for indx:=0; indx<numOlderFiles; indx++ {
+        if err := removeRollFile( dirPath, fileInfoSlice[indx].Name()); err!=nil {
+            return err
+        }
+    }
+    return nil 
+}
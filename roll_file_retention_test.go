@@ -0,0 +1,136 @@
+package dmlog
+
+import "io/ioutil"
+import "os"
+import "path/filepath"
+import "strings"
+import "testing"
+import "time"
+
+//--------------------------------------------------------------------------------------------------
+func TestEnforceRollRetention( t *testing.T) {
+    now := time.Date(2021, time.January, 6, 22, 29, 40, 0, time.UTC)
+    var testCases = []struct {
+        retention rollRetention
+        want string
+    }{
+        { rollRetention{ numMaxFiles: 10}, "log_1.txt,log_2.txt.gz,log_3.txt,log_4.txt" },
+        { rollRetention{ numMaxFiles: 3}, "log_3.txt,log_4.txt" },
+        { rollRetention{ numMaxFiles: 10, maxAge: 36*time.Hour}, "log_3.txt,log_4.txt" },
+        { rollRetention{ numMaxFiles: 10, maxAge: 72*time.Hour}, "log_2.txt.gz,log_3.txt,log_4.txt" },
+        { rollRetention{ numMaxFiles: 10, maxTotalSize: 3000}, "log_3.txt,log_4.txt" },
+        { rollRetention{ numMaxFiles: 10, maxTotalSize: 3500}, "log_2.txt.gz,log_3.txt,log_4.txt" },
+        { rollRetention{ numMaxFiles: 10, maxAge: 72*time.Hour, maxTotalSize: 1000}, "log_4.txt" },
+        { rollRetention{ numMaxFiles: 10, maxTotalSize: 3500, maxFileSize: 500}, "log_3.txt,log_4.txt" },
+    }
+    for indx, testCase := range testCases {
+        tempDirName, err := ioutil.TempDir("", "roll_file_retention_test")
+        if err != nil {
+            t.Error(t.Name(),`TempDir() failed:`,err)            
+            return
+        }
+        defer os.RemoveAll( tempDirName)
+        // The files, from the oldest to the newest.
+        files := []struct {
+            name string
+            size int
+            age time.Duration
+        }{
+            { "log_1.txt", 1000, 4*24*time.Hour },
+            { "log_2.txt.gz", 500, 2*24*time.Hour },
+            { "log_3.txt", 2000, 24*time.Hour },
+            { "log_4.txt", 1000, time.Hour },
+        }
+        for _, file := range files {
+            filePath := filepath.Join( tempDirName, file.name)
+            if err := ioutil.WriteFile( filePath, make([]byte, file.size), 0600); err!=nil {
+                t.Error(t.Name(),`WriteFile() failed:`,err)            
+                return
+            }
+            modTime := now.Add( -file.age)
+            if err := os.Chtimes( filePath, modTime, modTime); err!=nil {
+                t.Error(t.Name(),`Chtimes() failed:`,err)            
+                return
+            }
+        }
+
+        if err := enforceRollRetention( tempDirName, "log", &testCase.retention, now); err!=nil {
+            t.Error(t.Name(),`test case #`,indx,`: enforceRollRetention() failed:`,err)            
+            continue
+        }
+        fileInfos, err := listRollFiles( tempDirName, "log")
+        if err!=nil {
+            t.Error(t.Name(),`listRollFiles() failed:`,err)            
+            continue
+        }
+        got := make( []string, 0, len(fileInfos))
+        for _, fileInfo := range fileInfos {
+            got= append( got, fileInfo.Name())
+        }
+        if strings.Join( got, ",")!=testCase.want {
+            t.Error(t.Name(),`test case #`,indx,`: got`,got,`want`,testCase.want)            
+        }
+    }
+}
+
+//--------------------------------------------------------------------------------------------------
+func TestRollFileSinkMaxTotalSize( t *testing.T) {
+    tempDirName, err := ioutil.TempDir("", "roll_file_retention_test")
+    if err != nil {
+        t.Error(t.Name(),`TempDir() failed:`,err)            
+        return
+    }
+    defer os.RemoveAll( tempDirName)
+    options := RollFileSinkOptions{ MaxTotalSize: 3}
+    if _, err = newRollFileLogMessageSink( tempDirName, "log", 100, 4, DebugSeverity, options, time.Now); err==nil {
+        t.Error(t.Name(),`newRollFileLogMessageSink() accepted a max total size less than the max file size`)
+    }
+    sink, err := newRollFileLogMessageSink( tempDirName, "log", 100, 1, DebugSeverity, options, time.Now)
+    if err != nil {
+        t.Error(t.Name(),`newRollFileLogMessageSink() failed:`,err)            
+        return
+    }
+    logger := NewLogger()
+    defer logger.Terminate()
+    sinkId, err := logger.AddSink( sink)
+    if err!=nil {
+        t.Error(t.Name(),`AddSink() failed:`,err)            
+        return
+    }
+    logger.SetSinkOutputFormat( sinkId, LogMessageType, TextFmt)
+    for indx:=0; indx<200; indx++ {
+        logger.Info( strings.Repeat("x", 99))
+        if indx%10==0 {
+            // Distinct modification times, to keep the order of the files.
+            logger.Flush()
+            time.Sleep( time.Millisecond)
+        }
+    }
+    logger.Flush()
+
+    fileInfos, err := listRollFiles( tempDirName, "log")
+    if err!=nil {
+        t.Error(t.Name(),`listRollFiles() failed:`,err)            
+        return
+    }
+    var totalSize int64
+    for _, fileInfo := range fileInfos {
+        totalSize+= fileInfo.Size()
+    }
+    if totalSize>3*int64(kBytesToBytes) || len(fileInfos)<3 {
+        t.Error(t.Name(),`Unexpected files:`,len(fileInfos),`total size:`,totalSize)
+    }
+}
+
+//--------------------------------------------------------------------------------------------------
+func TestDiskFreeSpace( t *testing.T) {
+    if !isDiskFreeSpaceSupported {
+        t.Skip("free disk space not supported on this platform")
+    }
+    freeSpace, err := diskFreeSpace( os.TempDir())
+    if err!=nil {
+        t.Error(t.Name(),`diskFreeSpace() failed:`,err)            
+    } else if freeSpace==0 {
+        t.Log(t.Name(),`no free space on`,os.TempDir())            
+    }
+}
@@ -0,0 +1,84 @@
+package dmlog
+
+import "fmt"
+import "time"
+
+// Support for the time based rotation of the rolling file sink.
+
+// How often the rolling file sink starts a new file, regardless of the file size.
+type RollPeriod int8
+
+// The supported roll periods.
+const (
+    NoRollPeriod RollPeriod = iota  // Files are rolled only because of their size.
+    HourlyRollPeriod
+    DailyRollPeriod
+    WeeklyRollPeriod
+)
+
+// Implements the Stringable interface
+func (p RollPeriod) String() string {
+    switch p {
+        case NoRollPeriod:     return "none"
+        case HourlyRollPeriod: return "hourly"
+        case DailyRollPeriod:  return "daily"
+        case WeeklyRollPeriod: return "weekly"
+    }
+    return "Unknown"
+}
+
+// Checks that the time based rotation settings of the options are valid.
+func validateRollPeriod( options *RollFileSinkOptions) error {
+    switch options.Period {
+        case NoRollPeriod, HourlyRollPeriod, DailyRollPeriod, WeeklyRollPeriod:
+        default:
+            return fmt.Errorf("invalid roll period %d", options.Period)
+    }
+    if options.TimeOfDay<0 || options.TimeOfDay>=24*time.Hour {
+        return fmt.Errorf("invalid roll time of day %s", options.TimeOfDay)
+    }
+    if options.Weekday<time.Sunday || options.Weekday>time.Saturday {
+        return fmt.Errorf("invalid roll weekday %d", options.Weekday)
+    }
+    return nil
+}
+
+/* Retrieves the first rotation time strictly after now, according to the options.
+   The zero time is returned when the time based rotation is disabled. */
+func nextRollTime( now time.Time, options *RollFileSinkOptions) time.Time {
+    location := options.Location
+    if location == nil {
+        location= time.Local
+    }
+    t := now.In( location)
+    hour   := int( options.TimeOfDay/time.Hour)
+    minute := int( (options.TimeOfDay%time.Hour)/time.Minute)
+    second := int( (options.TimeOfDay%time.Minute)/time.Second)
+    nsec   := int( options.TimeOfDay%time.Second)
+
+    switch options.Period {
+        case HourlyRollPeriod: {
+            result := time.Date( t.Year(), t.Month(), t.Day(), t.Hour(), minute, second, nsec, location)
+            if !result.After( t) {
+                result= result.Add( time.Hour)
+            }
+            return result
+        }
+        case DailyRollPeriod: {
+            result := time.Date( t.Year(), t.Month(), t.Day(), hour, minute, second, nsec, location)
+            if !result.After( t) {
+                result= time.Date( t.Year(), t.Month(), t.Day()+1, hour, minute, second, nsec, location)
+            }
+            return result
+        }
+        case WeeklyRollPeriod: {
+            daysAhead := (int(options.Weekday) - int(t.Weekday()) + 7) % 7
+            result := time.Date( t.Year(), t.Month(), t.Day()+daysAhead, hour, minute, second, nsec, location)
+            if !result.After( t) {
+                result= time.Date( t.Year(), t.Month(), t.Day()+daysAhead+7, hour, minute, second, nsec, location)
+            }
+            return result
+        }
+    }
+    return time.Time{}
+}
@@ -0,0 +1,57 @@
+package dmlog
+
+import "testing"
+import "time"
+
+//--------------------------------------------------------------------------------------------------
+func TestNextRollTime( t *testing.T) {
+    location := time.FixedZone("UTC+2", 2*60*60)
+    // Wednesday.
+    now := time.Date(2021, time.January, 6, 22, 29, 40, 0, location)
+    var testCases = []struct {
+        options RollFileSinkOptions
+        want time.Time
+    }{
+        { RollFileSinkOptions{ Period: NoRollPeriod, Location: location}, 
+          time.Time{} },
+        { RollFileSinkOptions{ Period: HourlyRollPeriod, Location: location}, 
+          time.Date(2021, time.January, 6, 23, 0, 0, 0, location) },
+        { RollFileSinkOptions{ Period: HourlyRollPeriod, TimeOfDay: 45*time.Minute, Location: location}, 
+          time.Date(2021, time.January, 6, 22, 45, 0, 0, location) },
+        { RollFileSinkOptions{ Period: DailyRollPeriod, Location: location}, 
+          time.Date(2021, time.January, 7, 0, 0, 0, 0, location) },
+        { RollFileSinkOptions{ Period: DailyRollPeriod, TimeOfDay: 23*time.Hour, Location: location}, 
+          time.Date(2021, time.January, 6, 23, 0, 0, 0, location) },
+        { RollFileSinkOptions{ Period: DailyRollPeriod, TimeOfDay: 2*time.Hour, Location: time.UTC}, 
+          time.Date(2021, time.January, 7, 2, 0, 0, 0, time.UTC) },
+        { RollFileSinkOptions{ Period: WeeklyRollPeriod, Weekday: time.Wednesday, Location: location}, 
+          time.Date(2021, time.January, 13, 0, 0, 0, 0, location) },
+        { RollFileSinkOptions{ Period: WeeklyRollPeriod, Weekday: time.Monday, TimeOfDay: 6*time.Hour, 
+                               Location: location}, 
+          time.Date(2021, time.January, 11, 6, 0, 0, 0, location) },
+    }
+    for indx, testCase := range testCases {
+        got := nextRollTime( now, &testCase.options)
+        if !got.Equal( testCase.want) {
+            t.Error(t.Name(),`failed on test case #`,indx,`got:`,got,`want:`,testCase.want)
+        }
+    }
+}
+
+//--------------------------------------------------------------------------------------------------
+func TestValidateRollPeriod( t *testing.T) {
+    invalidOptions := []RollFileSinkOptions{
+        { Period: RollPeriod(10)},
+        { Period: DailyRollPeriod, TimeOfDay: 24*time.Hour},
+        { Period: DailyRollPeriod, TimeOfDay: -time.Second},
+        { Period: WeeklyRollPeriod, Weekday: time.Weekday(7)},
+    }
+    for indx, options := range invalidOptions {
+        if validateRollPeriod( &options)==nil {
+            t.Error(t.Name(),`test case #`,indx,`: got nil error`)
+        }
+    }
+    if err := validateRollPeriod( &RollFileSinkOptions{ Period: DailyRollPeriod}); err!=nil {
+        t.Error(t.Name(),`unexpected error:`,err)
+    }
+}
@@ -0,0 +1,93 @@
+package dmlog
+
+import "fmt"
+import "os"
+
+// The number of sink errors waiting for the error handler; further errors are only counted.
+const defaultCapChSinkErrors int = 100
+
+// An error reported by a sink.
+type sinkError struct {
+    sinkId MessageSinkId
+    err    error
+}
+
+/* Sinks embedding BaseLogMessageSink report their errors by ReportError(), and the messages they
+   dropped by ReportDroppedMessage(). */
+type errorReportingSink interface {
+    setErrorReporter( reporter func(error))
+    setDropReporter( reporter func())
+}
+
+/* Sets the function called with the errors reported by the sinks of the default logger, e.g. a
+   failed write because the disk is full.  The handler is called by a goroutine of the logger,
+   one error at a time; it can log messages.  A nil handler removes the current one. */
+func SetErrorHandler( handler func( sinkId MessageSinkId, err error)) {
+    DefaultLogger().SetErrorHandler( handler)
+}
+
+/* Sets whether the errors reported by the sinks of the default logger are printed on the 
+   standard error when there is no error handler.  It is disabled by default. */
+func SetErrorStderrFallback( isEnabled bool) {
+    DefaultLogger().SetErrorStderrFallback( isEnabled)
+}
+
+// Sets the function called with the errors reported by the sinks, see SetErrorHandler().
+func (l *Logger) SetErrorHandler( handler func( sinkId MessageSinkId, err error)) {
+    l.mtxErrorHandler.Lock()
+    defer l.mtxErrorHandler.Unlock()
+    l.errorHandler= handler
+}
+
+// Sets whether the sink errors are printed on the standard error, see SetErrorStderrFallback().
+func (l *Logger) SetErrorStderrFallback( isEnabled bool) {
+    l.mtxErrorHandler.Lock()
+    defer l.mtxErrorHandler.Unlock()
+    l.isErrorStderrFallback= isEnabled
+}
+
+//--------------------------------------------------------------------------------------------------
+/* Passes the errors reported by the sinks to the error handler.  It runs in its own goroutine, so
+   that the handler can log messages without blocking the dispatcher.  Once the logger is 
+   terminated, it handles the errors already queued, then exits. */
+func (l *Logger) sinkErrorHandler() {
+    for {
+        select {
+            case sinkErr := <- l.chSinkErrors:
+                l.handleSinkError( sinkErr)
+            case <- l.chReplyTerminate:
+                for {
+                    select {
+                        case sinkErr := <- l.chSinkErrors:
+                            l.handleSinkError( sinkErr)
+                        default:
+                            return
+                    }
+                }
+        }
+    }
+}
+
+//--------------------------------------------------------------------------------------------------
+func (l *Logger) handleSinkError( sinkErr sinkError) {
+    l.mtxErrorHandler.Lock()
+    handler := l.errorHandler
+    isErrorStderrFallback := l.isErrorStderrFallback
+    l.mtxErrorHandler.Unlock()
+    if handler!=nil {
+        handler( sinkErr.sinkId, sinkErr.err)
+    } else if isErrorStderrFallback {
+        fmt.Fprintf( os.Stderr, "dmlog: sink %d: %s\n", sinkErr.sinkId, sinkErr.err)
+    }
+}
+
+//--------------------------------------------------------------------------------------------------
+/* Records the error in the statistics of the sink and queues it for the error handler.  If the 
+   queue is full, the error is only counted. */
+func (l *Logger) reportSinkError( entry *sinkEntry, err error) {
+    entry.recordError( err)
+    select {
+        case l.chSinkErrors <- sinkError{ sinkId: entry.id, err: err}:
+        default:
+    }
+}
@@ -0,0 +1,48 @@
+package dmlog
+
+import "errors"
+import "strings"
+import "testing"
+import "time"
+
+// A writer always failing.
+type failingWriter struct{}
+
+func (f failingWriter) Write( p []byte) (int, error) {
+    return 0, errors.New("disk full")
+}
+
+//--------------------------------------------------------------------------------------------------
+func TestSinkErrorHandler( t *testing.T) {
+    type reportedError struct {
+        sinkId MessageSinkId
+        err    error
+    }
+    chErrors := make( chan reportedError, 10)
+    logger := NewLogger()
+    defer logger.Terminate()
+    logger.SetErrorHandler( func( sinkId MessageSinkId, err error) {
+                                chErrors <- reportedError{ sinkId, err}
+                            })
+    sinkId, err := logger.AddWriterSink( failingWriter{}, InfoSeverity)
+    if err!=nil {
+        t.Error(t.Name(),`AddWriterSink() failed:`,err)
+        return
+    }
+    logger.Info("Info message")
+    logger.Flush()
+
+    select {
+        case reported := <- chErrors:
+            if reported.sinkId!=sinkId || !strings.Contains( reported.err.Error(), "disk full") {
+                t.Error(t.Name(),`Unexpected error:`,reported.sinkId,reported.err)
+            }
+        case <- time.After( 5*time.Second):
+            t.Error(t.Name(),`The error was not reported`)
+            return
+    }
+    stats, ok := logger.SinkStatistics( sinkId)
+    if !ok || stats.Errors!=1 || stats.LastError==nil {
+        t.Error(t.Name(),`Unexpected statistics:`,ok,stats)
+    }
+}
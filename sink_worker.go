@@ -0,0 +1,281 @@
+package dmlog
+
+import "fmt"
+import "sync"
+import "sync/atomic"
+import "time"
+
+// The number of messages each sink can have waiting to be processed.
+const defaultCapSinkQueue int = 1000
+
+// The number of panics after which a sink is quarantined.
+const defaultSinkPanicLimit int32 = 3
+
+/* Statistics of a message sink.
+   Each sink processes its messages in its own goroutine, behind a bounded queue: when the queue
+   is full, the messages for that sink are dropped, so that a slow sink does not stall the others.
+   The drops are counted by the logger as well, see Backpressure. */
+type SinkStats struct {
+    // The number of messages waiting in the queue of the sink, and the queue capacity.
+    QueueLength   int
+    QueueCapacity int
+
+    /* The number of messages processed by the sink, and dropped because its queue was full, the
+       sink is quarantined or the sink itself dropped them, see ReportDroppedMessage(). */
+    Processed uint64
+    Dropped   uint64
+
+    /* The time from a message being issued to the sink having processed it: the average over the
+       processed messages, and the maximum. */
+    AverageLatency time.Duration
+    MaxLatency     time.Duration
+
+    // The number of errors reported by the sink, and the last one; nil if there were none.
+    Errors    uint64
+    LastError error
+
+    /* The number of panics recovered while the sink was processing messages or commands, and 
+       whether the sink is quarantined, see SetSinkPanicLimit(). */
+    Panics        uint64
+    IsQuarantined bool
+}
+
+/* A sink added to the dispatcher, with its identifier and the queue of the goroutine running it.
+   Once added, the sink methods are called only by that goroutine. */
+type sinkEntry struct {
+    // Updated atomically by the goroutine of the sink; first, to be 64 bits aligned.
+    processed     uint64
+    dropped       uint64
+    totalLatency  int64
+    maxLatency    int64
+    panics        uint64
+    // Set to 1 when the sink is quarantined.
+    isQuarantined int32
+
+    logger   *Logger
+    id       MessageSinkId
+    sink     *LogMessageSink
+    chTasks  chan sinkTask
+    // Closed when the goroutine of the sink exits, after the sink was terminated.
+    chDone   chan struct{}
+
+    // The errors reported by the sink, that can be reported by any goroutine.
+    mtxErrors     sync.Mutex
+    numErrors     uint64
+    lastError     error
+}
+
+// A message to be processed by a sink, or a command to be executed on it.
+type sinkTask struct {
+    message     *LogMessage
+    command     func()
+    isTerminate bool
+}
+
+/* Retrieves the statistics of the given sink.  The boolean is false if no sink has the given
+   sinkId. */
+func SinkStatistics( sinkId MessageSinkId) (SinkStats, bool) {
+    return DefaultLogger().SinkStatistics( sinkId)
+}
+
+/* Sets the number of panics after which a sink of the default logger is quarantined.
+   A panic of a sink is recovered and reported to the error handler, see SetErrorHandler(); the
+   other sinks and the logger keep working.  A quarantined sink does not receive messages any
+   more, they are counted as dropped.  A limit lower than one disables the quarantine.
+   The default is 3. */
+func SetSinkPanicLimit( limit int) {
+    DefaultLogger().SetSinkPanicLimit( limit)
+}
+
+// Retrieves the statistics of a sink of the logger, see SinkStatistics().
+func (l *Logger) SinkStatistics( sinkId MessageSinkId) (SinkStats, bool) {
+    return l.reqSinkStats( sinkId)
+}
+
+// Sets the number of panics after which a sink is quarantined, see SetSinkPanicLimit().
+func (l *Logger) SetSinkPanicLimit( limit int) {
+    atomic.StoreInt32( &l.sinkPanicLimit, int32(limit))
+}
+
+//--------------------------------------------------------------------------------------------------
+/* Creates the entry of a sink and starts its goroutine.  The sink errors are reported to the 
+   logger. */
+func newSinkEntry( l *Logger, id MessageSinkId, sink *LogMessageSink) *sinkEntry {
+    entry := &sinkEntry{ logger: l,
+                         id: id,
+                         sink: sink,
+                         chTasks: make( chan sinkTask, defaultCapSinkQueue),
+                         chDone: make( chan struct{}), }
+    if reporting, ok := (*sink).(errorReportingSink); ok {
+        reporting.setErrorReporter( func( err error) { l.reportSinkError( entry, err) })
+        reporting.setDropReporter( entry.recordDropped)
+    }
+    go entry.run()
+    return entry
+}
+
+//--------------------------------------------------------------------------------------------------
+func (e *sinkEntry) run() {
+    defer close( e.chDone)
+    for {
+        task := <- e.chTasks
+        e.runTask( task)
+        if task.isTerminate {
+            return
+        }
+    }
+}
+
+//--------------------------------------------------------------------------------------------------
+// Processes the message or executes the command, recovering a panic of the sink.
+func (e *sinkEntry) runTask( task sinkTask) {
+    defer e.recoverPanic()
+    if task.message==nil {
+        task.command()
+        return
+    }
+    if atomic.LoadInt32( &e.isQuarantined)!=0 {
+        atomic.AddUint64( &e.dropped, 1)
+        return
+    }
+    (*e.sink).OnLogMessage( task.message)
+    latency := int64( time.Since( task.message.timestamp))
+    atomic.AddUint64( &e.processed, 1)
+    atomic.AddInt64( &e.totalLatency, latency)
+    if latency>atomic.LoadInt64( &e.maxLatency) {
+        atomic.StoreInt64( &e.maxLatency, latency)
+    }
+}
+
+//--------------------------------------------------------------------------------------------------
+// Reports a panic of the sink, quarantining the sink once it reached the panic limit.
+func (e *sinkEntry) recoverPanic() {
+    recovered := recover()
+    if recovered==nil {
+        return
+    }
+    numPanics := atomic.AddUint64( &e.panics, 1)
+    e.logger.reportSinkError( e, fmt.Errorf("the sink panicked: %v", recovered))
+    limit := atomic.LoadInt32( &e.logger.sinkPanicLimit)
+    if limit>0 && numPanics>=uint64(limit) && atomic.CompareAndSwapInt32( &e.isQuarantined, 0, 1) {
+        e.logger.reportSinkError( e, fmt.Errorf("the sink is quarantined after %d panics", numPanics))
+    }
+}
+
+//--------------------------------------------------------------------------------------------------
+/* Queues the message for the sink.  If the queue is full, the message is dropped for this sink
+   only, unless isBlocking is true: the dispatcher never waits for a slow sink, that would stall
+   the others.  Returns false if the message was dropped. */
+func (e *sinkEntry) post( message *LogMessage, isBlocking bool) bool {
+    if isBlocking {
+        e.chTasks <- sinkTask{ message: message}
+        return true
+    }
+    select {
+        case e.chTasks <- sinkTask{ message: message}:
+            return true
+        default:
+            atomic.AddUint64( &e.dropped, 1)
+            return false
+    }
+}
+
+//--------------------------------------------------------------------------------------------------
+/* Queues the command to be executed in the goroutine of the sink, after the messages already
+   queued, without waiting: if the queue is full, the command is queued by the returned function.
+   The returned function waits for the execution; it returns false if the sink was terminated
+   before executing the command. */
+func (e *sinkEntry) submit( command func(), isTerminate bool) func() bool {
+    chExecuted := make( chan struct{})
+    task := sinkTask{ command: func() {
+                                   // Closed also if the command panics.
+                                   defer close( chExecuted)
+                                   command()
+                               },
+                      isTerminate: isTerminate, }
+    isQueued := false
+    select {
+        case e.chTasks <- task:
+            isQueued= true
+        default:
+    }
+    return func() bool {
+        if !isQueued {
+            select {
+                case e.chTasks <- task:
+                case <- e.chDone:
+                    return false
+            }
+        }
+        select {
+            case <- chExecuted:
+                return true
+            case <- e.chDone:
+                select {
+                    case <- chExecuted:
+                        return true
+                    default:
+                        return false
+                }
+        }
+    }
+}
+
+//--------------------------------------------------------------------------------------------------
+/* Executes the command in the goroutine of the sink, after the messages already queued, and waits
+   for it.  Returns false if the sink was terminated before executing it. */
+func (e *sinkEntry) execute( command func()) bool {
+    return e.submit( command, false)()
+}
+
+//--------------------------------------------------------------------------------------------------
+/* Queues the termination of the sink, after the messages already queued, see submit().  The 
+   returned function waits for the goroutine of the sink to exit. */
+func (e *sinkEntry) submitTerminate() func() {
+    wait := e.submit( (*e.sink).Terminate, true)
+    return func() {
+        wait()
+        <- e.chDone
+    }
+}
+
+//--------------------------------------------------------------------------------------------------
+// Terminates the sink after the messages already queued, and waits for its goroutine to exit.
+func (e *sinkEntry) terminate() {
+    e.submitTerminate()()
+}
+
+//--------------------------------------------------------------------------------------------------
+// Counts a message dropped by the sink itself, in its statistics and in those of the logger.
+func (e *sinkEntry) recordDropped() {
+    atomic.AddUint64( &e.dropped, 1)
+    atomic.AddUint64( &e.logger.droppedMessages, 1)
+}
+
+//--------------------------------------------------------------------------------------------------
+func (e *sinkEntry) recordError( err error) {
+    e.mtxErrors.Lock()
+    defer e.mtxErrors.Unlock()
+    e.numErrors++
+    e.lastError= err
+}
+
+//--------------------------------------------------------------------------------------------------
+func (e *sinkEntry) stats() SinkStats {
+    processed := atomic.LoadUint64( &e.processed)
+    result := SinkStats{ QueueLength: len(e.chTasks),
+                         QueueCapacity: cap(e.chTasks),
+                         Processed: processed,
+                         Dropped: atomic.LoadUint64( &e.dropped),
+                         MaxLatency: time.Duration( atomic.LoadInt64( &e.maxLatency)),
+                         Panics: atomic.LoadUint64( &e.panics),
+                         IsQuarantined: atomic.LoadInt32( &e.isQuarantined)!=0, }
+    e.mtxErrors.Lock()
+    result.Errors= e.numErrors
+    result.LastError= e.lastError
+    e.mtxErrors.Unlock()
+    if processed>0 {
+        result.AverageLatency= time.Duration( atomic.LoadInt64( &e.totalLatency)/ int64(processed))
+    }
+    return result
+}
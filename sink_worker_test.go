@@ -0,0 +1,241 @@
+package dmlog
+
+import "errors"
+import "strings"
+import "testing"
+import "time"
+
+// A sink whose OnLogMessage waits until chRelease is closed, like a hung file system.
+type blockingLogMessageSink struct {
+    BaseLogMessageSink
+    chRelease chan struct{}
+}
+
+func (b *blockingLogMessageSink) OnLogMessage( msg *LogMessage) {
+    <- b.chRelease
+}
+
+func (b *blockingLogMessageSink) Flush() {}
+
+func (b *blockingLogMessageSink) Terminate() {}
+
+// A sink whose OnLogMessage panics on the messages with text "panic".
+type panickingLogMessageSink struct {
+    BaseLogMessageSink
+}
+
+func (p *panickingLogMessageSink) OnLogMessage( msg *LogMessage) {
+    if msg.Text()=="panic" {
+        panic("sink failure")
+    }
+}
+
+func (p *panickingLogMessageSink) Flush() {}
+
+func (p *panickingLogMessageSink) Terminate() {}
+
+// A sink whose FlushError panics.
+type panickingFlushLogMessageSink struct {
+    recordingLogMessageSink
+}
+
+func (p *panickingFlushLogMessageSink) FlushError() error {
+    panic("flush failure")
+}
+
+//--------------------------------------------------------------------------------------------------
+func TestSlowSinkDoesNotStall( t *testing.T) {
+    logger := NewLogger()
+    defer logger.Terminate()
+    blockingSink := &blockingLogMessageSink{ BaseLogMessageSink: NewBaseLogMessageSink( DebugSeverity, false),
+                                             chRelease: make( chan struct{}), }
+    blockingSinkId, err := logger.AddSink( blockingSink)
+    if err!=nil {
+        t.Error(t.Name(),`AddSink() failed:`,err)
+        return
+    }
+    recordingSink := &recordingLogMessageSink{ BaseLogMessageSink: NewBaseLogMessageSink( DebugSeverity, false) }
+    recordingSinkId, err := logger.AddSink( recordingSink)
+    if err!=nil {
+        t.Error(t.Name(),`AddSink() failed:`,err)
+        return
+    }
+    numMessages := defaultCapSinkQueue+ 5
+    for indx:=0; indx<numMessages; indx++ {
+        logger.Info("Info message")
+    }
+    // The recording sink processes all the messages, while the other sink is blocked.
+    deadline := time.Now().Add( 10*time.Second)
+    for {
+        stats, ok := logger.SinkStatistics( recordingSinkId)
+        if !ok {
+            t.Error(t.Name(),`SinkStatistics() failed`)
+            close( blockingSink.chRelease)
+            return
+        }
+        if stats.Processed+stats.Dropped==uint64(numMessages) {
+            break
+        }
+        if time.Now().After( deadline) {
+            t.Error(t.Name(),`The recording sink was stalled:`,stats)
+            close( blockingSink.chRelease)
+            return
+        }
+        time.Sleep( time.Millisecond)
+    }
+
+    stats, _ := logger.SinkStatistics( blockingSinkId)
+    if stats.Dropped==0 || stats.QueueLength!=defaultCapSinkQueue || 
+       stats.QueueCapacity!=defaultCapSinkQueue {
+        t.Error(t.Name(),`Unexpected statistics of the blocked sink:`,stats)
+    }
+    if logger.DroppedMessages()<stats.Dropped {
+        t.Error(t.Name(),`Unexpected dropped messages:`,logger.DroppedMessages(),stats.Dropped)
+    }
+    close( blockingSink.chRelease)
+    if err := logger.Flush(); !errors.Is( err, ErrDroppedMessages) {
+        t.Error(t.Name(),`Flush() did not report the dropped messages:`,err)
+    }
+    if err := logger.Flush(); err!=nil {
+        t.Error(t.Name(),`Flush() failed:`,err)
+    }
+    stats, _ = logger.SinkStatistics( blockingSinkId)
+    if stats.Processed+stats.Dropped!=uint64(numMessages)+1 || stats.QueueLength!=0 || 
+       stats.MaxLatency<stats.AverageLatency {
+        t.Error(t.Name(),`Unexpected statistics of the released sink:`,stats)
+    }
+    if _, ok := logger.SinkStatistics( MessageSinkId(1000)); ok {
+        t.Error(t.Name(),`SinkStatistics() succeeded on a missing sink`)
+    }
+}
+
+//--------------------------------------------------------------------------------------------------
+func TestSinkPanicIsolation( t *testing.T) {
+    chErrors := make( chan error, 10)
+    logger := NewLogger()
+    defer logger.Terminate()
+    logger.SetErrorHandler( func( sinkId MessageSinkId, err error) { chErrors <- err })
+    logger.SetSinkPanicLimit( 2)
+    panickingSinkId, err := logger.AddSink( &panickingLogMessageSink{
+                                                BaseLogMessageSink: NewBaseLogMessageSink( DebugSeverity, false)})
+    if err!=nil {
+        t.Error(t.Name(),`AddSink() failed:`,err)
+        return
+    }
+    recordingSink := &recordingLogMessageSink{ BaseLogMessageSink: NewBaseLogMessageSink( DebugSeverity, false) }
+    if _, err = logger.AddSink( recordingSink); err!=nil {
+        t.Error(t.Name(),`AddSink() failed:`,err)
+        return
+    }
+
+    logger.Info("panic")
+    logger.Info("First message")
+    logger.Info("panic")
+    logger.Info("Second message")
+    if err := logger.Flush(); err!=nil {
+        t.Error(t.Name(),`Flush() failed:`,err)
+    }
+
+    stats, _ := logger.SinkStatistics( panickingSinkId)
+    if stats.Panics!=2 || !stats.IsQuarantined || stats.Processed!=1 || stats.Dropped!=1 {
+        t.Error(t.Name(),`Unexpected statistics of the panicking sink:`,stats)
+    }
+    if strings.Join( recordingSink.texts, ",")!="panic,First message,panic,Second message" {
+        t.Error(t.Name(),`Unexpected messages of the recording sink:`,recordingSink.texts)
+    }
+    expected := []string{ "the sink panicked: sink failure", "the sink panicked: sink failure", 
+                          "the sink is quarantined after 2 panics", }
+    for _, expectedErr := range expected {
+        select {
+            case err := <- chErrors:
+                if err.Error()!=expectedErr {
+                    t.Error(t.Name(),`Unexpected error:`,err,`expected:`,expectedErr)
+                }
+            case <- time.After( 5*time.Second):
+                t.Error(t.Name(),`The error was not reported:`,expectedErr)
+                return
+        }
+    }
+}
+
+//--------------------------------------------------------------------------------------------------
+func TestSinkFlushPanic( t *testing.T) {
+    logger := NewLogger()
+    defer logger.Terminate()
+    sink := &panickingFlushLogMessageSink{}
+    sink.BaseLogMessageSink= NewBaseLogMessageSink( DebugSeverity, false)
+    sinkId, err := logger.AddSink( sink)
+    if err!=nil {
+        t.Error(t.Name(),`AddSink() failed:`,err)
+        return
+    }
+    err = logger.Flush()
+    if err==nil || !strings.Contains( err.Error(), "the sink panicked: flush failure") {
+        t.Error(t.Name(),`Flush() did not report the panic:`,err)
+    }
+    if stats, _ := logger.SinkStatistics( sinkId); stats.Panics!=1 {
+        t.Error(t.Name(),`Unexpected statistics:`,stats)
+    }
+}
+
+//--------------------------------------------------------------------------------------------------
+func TestStalledSinkDoesNotBlockRequests( t *testing.T) {
+    logger := NewLogger()
+    defer logger.Terminate()
+    blockingSink := &blockingLogMessageSink{ BaseLogMessageSink: NewBaseLogMessageSink( DebugSeverity, false),
+                                             chRelease: make( chan struct{}), }
+    blockingSinkId, err := logger.AddSink( blockingSink)
+    if err!=nil {
+        t.Error(t.Name(),`AddSink() failed:`,err)
+        return
+    }
+    recordingSink := &recordingLogMessageSink{ BaseLogMessageSink: NewBaseLogMessageSink( DebugSeverity, false) }
+    recordingSinkId, err := logger.AddSink( recordingSink)
+    if err!=nil {
+        t.Error(t.Name(),`AddSink() failed:`,err)
+        return
+    }
+    logger.Info("Stalling message")
+
+    // The request on the stalled sink waits for it, without blocking the dispatcher.
+    chThresholdSet := make( chan bool, 1)
+    go func() { chThresholdSet <- logger.SetMessageSinkSeverity( blockingSinkId, WarningSeverity) }()
+    chDone := make( chan struct{})
+    go func() {
+        defer close( chDone)
+        if !logger.SetMessageSinkSeverity( recordingSinkId, WarningSeverity) {
+            t.Error(t.Name(),`SetMessageSinkSeverity() failed`)
+        }
+        if !logger.SetSinkOutputFormat( recordingSinkId, LogMessageType, TextFmt) {
+            t.Error(t.Name(),`SetSinkOutputFormat() failed`)
+        }
+        logger.Info("Info message")
+        logger.Warn("Warning message")
+        for {
+            if stats, _ := logger.SinkStatistics( recordingSinkId); stats.Processed==3 {
+                return
+            }
+            time.Sleep( time.Millisecond)
+        }
+    }()
+    select {
+        case <- chDone:
+        case <- time.After( 10*time.Second):
+            t.Error(t.Name(),`The requests were blocked by the stalled sink`)
+            return
+    }
+    select {
+        case <- chThresholdSet:
+            t.Error(t.Name(),`The request on the stalled sink did not wait`)
+        default:
+    }
+
+    close( blockingSink.chRelease)
+    if !<- chThresholdSet {
+        t.Error(t.Name(),`SetMessageSinkSeverity() failed on the released sink`)
+    }
+    logger.Flush()
+    if strings.Join( recordingSink.texts, ",")!="Stalling message,Warning message" {
+        t.Error(t.Name(),`Unexpected messages:`,recordingSink.texts)
+    }
+}
@@ -0,0 +1,378 @@
+package dmlog
+
+import "fmt"
+import "net"
+import "os"
+import "strconv"
+import "strings"
+import "time"
+
+// The syslog facility of the messages, as defined by RFC 5424.
+type SyslogFacility int8
+
+// The supported syslog facilities.
+const (
+    KernSyslogFacility SyslogFacility = iota
+    UserSyslogFacility
+    MailSyslogFacility
+    DaemonSyslogFacility
+    AuthSyslogFacility
+    SyslogSyslogFacility
+    LprSyslogFacility
+    NewsSyslogFacility
+    UucpSyslogFacility
+    CronSyslogFacility
+    AuthprivSyslogFacility
+    FtpSyslogFacility
+    NtpSyslogFacility
+    SecuritySyslogFacility
+    ConsoleSyslogFacility
+    SolarisCronSyslogFacility
+    Local0SyslogFacility
+    Local1SyslogFacility
+    Local2SyslogFacility
+    Local3SyslogFacility
+    Local4SyslogFacility
+    Local5SyslogFacility
+    Local6SyslogFacility
+    Local7SyslogFacility
+)
+
+// The format of the syslog messages.
+type SyslogProtocol int8
+
+// The supported syslog formats.
+const (
+    RFC5424SyslogProtocol SyslogProtocol = iota // The current syslog protocol, with structured data.
+    RFC3164SyslogProtocol // The legacy BSD syslog protocol.
+)
+
+// The enterprise number of the structured data ids, reserved by IANA for documentation.
+const syslogEnterpriseId string = "32473"
+
+const syslogTimestampFormat string = "2006-01-02T15:04:05.000000Z07:00"
+const syslogWriteTimeout = 5*time.Second
+const syslogDialTimeout = 5*time.Second
+
+// After a failed connection, no new attempt is done before this interval elapsed.
+const syslogRedialInterval = time.Second
+
+const syslogNilValue string = "-"
+
+// Implementation of a log sink that sends messages to a syslog server.
+type syslogLogMessageSink struct {
+    BaseLogMessageSink
+    network       string
+    address       string
+    facility      SyslogFacility
+    appName       string
+    protocol      SyslogProtocol
+    hostname      string
+    pid           int
+    conn          net.Conn
+    lastDialTime  time.Time
+    // Set when a message is dropped, to report only the first drop until a message is sent again.
+    isDropReported bool
+}
+
+/* Adds a log message sink that sends messages to a syslog server using RFC 5424.
+   network is "udp", "tcp", "unix" or "unixgram", or one of their variants accepted by net.Dial().
+   Over stream connections the messages are framed by octet counting, as in RFC 6587.
+   The message text becomes the syslog MSG; the caller and the fields are sent as structured data.
+   The sink connects when the first message is issued, so the server need not be reachable yet.  If
+   the connection fails, the sink connects again when the next message is issued, at most once per
+   second; the messages issued meanwhile are dropped and counted, see SinkStats.
+   In case error is nil, the returned message sink id can be used later to modify the severity
+   threshold.*/
+func AddSyslogSink( network string, 
+                    address string, 
+                    facility SyslogFacility, 
+                    appName string, 
+                    threshold LogSeverity) (MessageSinkId, error) {
+    return DefaultLogger().AddSyslogSinkWithProtocol( network, address, facility, appName, 
+                                                    RFC5424SyslogProtocol, threshold)
+}
+
+// Same as AddSyslogSink(), the protocol can be the legacy RFC 3164 one.
+func AddSyslogSinkWithProtocol( network string, 
+                                address string, 
+                                facility SyslogFacility, 
+                                appName string, 
+                                protocol SyslogProtocol,
+                                threshold LogSeverity) (MessageSinkId, error) {
+    return DefaultLogger().AddSyslogSinkWithProtocol( network, address, facility, appName, 
+                                                    protocol, threshold)
+}
+
+// Adds to the logger a sink that sends messages to a syslog server, see AddSyslogSink().
+func (l *Logger) AddSyslogSink( network string, 
+                                address string, 
+                                facility SyslogFacility, 
+                                appName string, 
+                                threshold LogSeverity) (MessageSinkId, error) {
+    return l.AddSyslogSinkWithProtocol( network, address, facility, appName, 
+                                        RFC5424SyslogProtocol, threshold)
+}
+
+// Adds to the logger a sink that sends messages to a syslog server, see AddSyslogSinkWithProtocol().
+func (l *Logger) AddSyslogSinkWithProtocol( network string, 
+                                            address string, 
+                                            facility SyslogFacility, 
+                                            appName string, 
+                                            protocol SyslogProtocol,
+                                            threshold LogSeverity) (MessageSinkId, error) {
+    msgSink, err := newSyslogLogMessageSink( network, address, facility, appName, protocol, threshold)
+    if err!=nil {
+        return MessageSinkId(0), err
+    }
+    return l.addMessageSink( msgSink)
+}
+
+//--------------------------------------------------------------------------------------------------
+func newSyslogLogMessageSink( network string, 
+                              address string, 
+                              facility SyslogFacility, 
+                              appName string, 
+                              protocol SyslogProtocol,
+                              threshold LogSeverity) (*syslogLogMessageSink, error) {
+    if facility<KernSyslogFacility || facility>Local7SyslogFacility {
+        return nil, fmt.Errorf("invalid syslog facility %d", facility)
+    }
+    if protocol!=RFC5424SyslogProtocol && protocol!=RFC3164SyslogProtocol {
+        return nil, fmt.Errorf("invalid syslog protocol %d", protocol)
+    }
+    hostname, err := os.Hostname()
+    if err!=nil || len(hostname)==0 {
+        hostname= syslogNilValue
+    }
+    obj := syslogLogMessageSink{ BaseLogMessageSink: NewBaseLogMessageSink( threshold, false),
+                                 network: network,
+                                 address: address,
+                                 facility: facility,
+                                 appName: syslogHeaderField( appName, 48),
+                                 protocol: protocol,
+                                 hostname: syslogHeaderField( hostname, 255),
+                                 pid: os.Getpid(), }
+    return &obj, nil
+}
+
+//--------------------------------------------------------------------------------------------------
+func (s *syslogLogMessageSink) OnLogMessage( msg *LogMessage) {
+    if ! msg.severity.IsGreaterOrEqualThan( s.threshold) {
+        return
+    }
+    var packet string
+    if s.protocol==RFC3164SyslogProtocol {
+        packet= s.formatRFC3164( msg)
+    } else {
+        packet= s.formatRFC5424( msg)
+    }
+    if s.isStream() {
+        packet= strconv.Itoa( len(packet))+ " "+ packet
+    }
+    if err := s.send( packet); err!=nil {
+        s.ReportDroppedMessage()
+        if !s.isDropReported {
+            s.isDropReported= true
+            s.ReportError( fmt.Errorf("messages dropped:%w", err))
+        }
+        return
+    }
+    s.isDropReported= false
+}
+
+//--------------------------------------------------------------------------------------------------
+func (s *syslogLogMessageSink) Flush() {
+}
+
+//--------------------------------------------------------------------------------------------------
+func (s *syslogLogMessageSink) Terminate() {
+    s.disconnect()
+}
+
+//--------------------------------------------------------------------------------------------------
+// Determines whether the messages are sent over a stream connection, that needs framing.
+func (s *syslogLogMessageSink) isStream() bool {
+    switch s.network {
+        case "udp", "udp4", "udp6", "unixgram":
+            return false
+    }
+    return true
+}
+
+//--------------------------------------------------------------------------------------------------
+func (s *syslogLogMessageSink) connect() error {
+    s.lastDialTime= time.Now()
+    conn, err := net.DialTimeout( s.network, s.address, syslogDialTimeout)
+    if err!=nil {
+        return fmt.Errorf("failed while trying to connect to %s %s:%s", s.network, s.address, err)
+    }
+    s.conn= conn
+    return nil
+}
+
+//--------------------------------------------------------------------------------------------------
+func (s *syslogLogMessageSink) disconnect() {
+    if s.conn!=nil {
+        s.conn.Close()
+        s.conn= nil
+    }
+}
+
+//--------------------------------------------------------------------------------------------------
+/* Sends the packet, connecting again if the connection is broken.  The packet is dropped if the 
+   connection cannot be restored. */
+func (s *syslogLogMessageSink) send( packet string) error {
+    var err error
+    for attempt:=0; attempt<2; attempt++ {
+        if s.conn==nil {
+            if time.Since( s.lastDialTime)<syslogRedialInterval && attempt==0 {
+                return fmt.Errorf("not connected to %s %s", s.network, s.address)
+            }
+            if err = s.connect(); err!=nil {
+                return err
+            }
+        }
+        s.conn.SetWriteDeadline( time.Now().Add( syslogWriteTimeout))
+        if _, err = s.conn.Write( []byte(packet)); err==nil {
+            return nil
+        }
+        err= fmt.Errorf("failed while trying to send to %s %s:%w", s.network, s.address, err)
+        s.disconnect()
+    }
+    return err
+}
+
+//--------------------------------------------------------------------------------------------------
+// Retrieves the syslog priority of the message: the facility combined with the severity.
+func (s *syslogLogMessageSink) priority( severity LogSeverity) int {
+    return int(s.facility)*8 + syslogSeverity( severity)
+}
+
+// Maps the log severity to the syslog one.
+func syslogSeverity( severity LogSeverity) int {
+    switch severity {
+        case DebugSeverity:   return 7 // Debug
+        case InfoSeverity:    return 6 // Informational
+        case PrintSeverity:   return 5 // Notice
+        case WarningSeverity: return 4 // Warning
+        case ErrorSeverity:   return 3 // Error
+        case FatalSeverity:   return 2 // Critical
+    }
+    return 5
+}
+
+//--------------------------------------------------------------------------------------------------
+/* Formats the message according to RFC 5424:
+   <PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID STRUCTURED-DATA MSG */
+func (s *syslogLogMessageSink) formatRFC5424( msg *LogMessage) string {
+    var result strings.Builder
+    result.Grow(defaultFormattedLogMessageCapacity)
+    result.WriteString("<")
+    result.WriteString( strconv.Itoa( s.priority( msg.severity)))
+    result.WriteString(">1 ")
+    result.WriteString( msg.timestamp.Format( syslogTimestampFormat))
+    result.WriteString(" ")
+    result.WriteString( s.hostname)
+    result.WriteString(" ")
+    result.WriteString( s.appName)
+    result.WriteString(" ")
+    result.WriteString( strconv.Itoa( s.pid))
+    result.WriteString(" ")
+    result.WriteString( syslogNilValue)
+    result.WriteString(" [caller@"+ syslogEnterpriseId)
+    writeSyslogParam( &result, "file", msg.filename)
+    writeSyslogParam( &result, "line", strconv.Itoa( msg.line))
+    writeSyslogParam( &result, "func", msg.funcName)
+    result.WriteString("]")
+    if len(msg.fields)>0 {
+        result.WriteString("[fields@"+ syslogEnterpriseId)
+        for _, field := range msg.fields {
+            writeSyslogParam( &result, field.Key, fmt.Sprint( field.Value))
+        }
+        result.WriteString("]")
+    }
+    if len(msg.text)>0 {
+        result.WriteString(" ")
+        result.WriteString( msg.text)
+    }
+    return result.String()
+}
+
+//--------------------------------------------------------------------------------------------------
+/* Formats the message according to RFC 3164:
+   <PRI>Mmm dd hh:mm:ss HOSTNAME TAG[PID]: MSG 
+   The fields are appended to the message text as key=value pairs. */
+func (s *syslogLogMessageSink) formatRFC3164( msg *LogMessage) string {
+    var result strings.Builder
+    result.Grow(defaultFormattedLogMessageCapacity)
+    result.WriteString("<")
+    result.WriteString( strconv.Itoa( s.priority( msg.severity)))
+    result.WriteString(">")
+    result.WriteString( msg.timestamp.Format( time.Stamp))
+    result.WriteString(" ")
+    result.WriteString( s.hostname)
+    result.WriteString(" ")
+    result.WriteString( s.appName)
+    result.WriteString("[")
+    result.WriteString( strconv.Itoa( s.pid))
+    result.WriteString("]: ")
+    result.WriteString( msg.text)
+    if len(msg.fields)>0 {
+        result.WriteString(" ")
+        result.WriteString( formatFields( msg.fields))
+    }
+    return result.String()
+}
+
+//--------------------------------------------------------------------------------------------------
+// Writes a structured data parameter: the name is sanitized and the value escaped.
+func writeSyslogParam( result *strings.Builder, name string, value string) {
+    result.WriteString(" ")
+    result.WriteString( syslogParamName( name))
+    result.WriteString(`="`)
+    for _, r := range value {
+        switch r {
+            case '"', '\\', ']':
+                result.WriteRune('\\')
+        }
+        result.WriteRune(r)
+    }
+    result.WriteString(`"`)
+}
+
+/* Retrieves a valid structured data name: at most 32 printable ASCII characters, except '=', 
+   ' ', ']' and '"', that are replaced by '_'. */
+func syslogParamName( name string) string {
+    name= strings.Map( func(r rune) rune {
+                            if r<=' ' || r>'~' || r=='=' || r==']' || r=='"' {
+                                return '_'
+                            }
+                            return r
+                        }, name)
+    if len(name)==0 {
+        return "_"
+    }
+    if len(name)>32 {
+        return name[:32]
+    }
+    return name
+}
+
+/* Retrieves a valid header field: printable ASCII characters only, at most maxLen of them, the
+   nil value "-" if empty. */
+func syslogHeaderField( value string, maxLen int) string {
+    value= strings.Map( func(r rune) rune {
+                            if r<=' ' || r>'~' {
+                                return '_'
+                            }
+                            return r
+                        }, value)
+    if len(value)==0 {
+        return syslogNilValue
+    }
+    if len(value)>maxLen {
+        return value[:maxLen]
+    }
+    return value
+}
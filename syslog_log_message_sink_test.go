@@ -0,0 +1,212 @@
+package dmlog
+
+import "bufio"
+import "io"
+import "net"
+import "strconv"
+import "strings"
+import "testing"
+import "time"
+
+//--------------------------------------------------------------------------------------------------
+func TestSyslogFormat( t *testing.T) {
+    sink := syslogLogMessageSink{ facility: Local0SyslogFacility,
+                                  appName: syslogHeaderField("my app", 48),
+                                  hostname: "host",
+                                  pid: 42, }
+    timestamp := time.Date( 2021, 1, 6, 23, 29, 40, 123456000, time.UTC)
+    msg := NewLogMessage( "Text message", WarningSeverity, LogMessageType, timestamp, 
+                          "main.go", 32, "main.main", NewField("user","a\"b]c"))
+
+    expected := `<132>1 2021-01-06T23:29:40.123456Z host my_app 42 - `+
+                `[caller@32473 file="main.go" line="32" func="main.main"]`+
+                `[fields@32473 user="a\"b\]c"] Text message`
+    if got := sink.formatRFC5424( msg); got!=expected {
+        t.Error(t.Name(),`Unexpected RFC 5424 message: got:`,got,`expected:`,expected)
+    }
+
+    expected = `<132>Jan  6 23:29:40 host my_app[42]: Text message user="a\"b]c"`
+    if got := sink.formatRFC3164( msg); got!=expected {
+        t.Error(t.Name(),`Unexpected RFC 3164 message: got:`,got,`expected:`,expected)
+    }
+}
+
+//--------------------------------------------------------------------------------------------------
+func TestSyslogSeverity( t *testing.T) {
+    expected := map[LogSeverity]int { DebugSeverity:7, InfoSeverity:6, PrintSeverity:5, 
+                                      WarningSeverity:4, ErrorSeverity:3, FatalSeverity:2, }
+    for severity, syslogSev := range expected {
+        if got := syslogSeverity( severity); got!=syslogSev {
+            t.Error(t.Name(),`Unexpected syslog severity for`,severity,`got:`,got,`expected:`,syslogSev)
+        }
+    }
+}
+
+//--------------------------------------------------------------------------------------------------
+func TestSyslogSinkUDP( t *testing.T) {
+    conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+    if err!=nil {
+        t.Error(t.Name(),`ListenPacket() failed:`,err)
+        return
+    }
+    defer conn.Close()
+
+    logger := NewLogger()
+    defer logger.Terminate()
+    _, err = logger.AddSyslogSink("udp", conn.LocalAddr().String(), UserSyslogFacility, "test", InfoSeverity)
+    if err!=nil {
+        t.Error(t.Name(),`AddSyslogSink() failed:`,err)
+        return
+    }
+    logger.SetSeverity( DebugSeverity)
+    logger.Debug("Debug message")
+    logger.Info("Info message")
+    logger.Flush()
+
+    conn.SetReadDeadline( time.Now().Add( 5*time.Second))
+    buffer := make([]byte, 2048)
+    n, _, err := conn.ReadFrom( buffer)
+    if err!=nil {
+        t.Error(t.Name(),`ReadFrom() failed:`,err)
+        return
+    }
+    packet := string(buffer[:n])
+    if !strings.HasPrefix( packet, "<14>1 ") || !strings.HasSuffix( packet, "] Info message") {
+        t.Error(t.Name(),`Unexpected packet:`,packet)
+    }
+}
+
+//--------------------------------------------------------------------------------------------------
+// Reads a message framed by octet counting.
+func readOctetCountedMessage( reader *bufio.Reader) (string, error) {
+    lenText, err := reader.ReadString(' ')
+    if err!=nil {
+        return "", err
+    }
+    msgLen, err := strconv.Atoi( strings.TrimSuffix( lenText, " "))
+    if err!=nil {
+        return "", err
+    }
+    buffer := make([]byte, msgLen)
+    if _, err := io.ReadFull( reader, buffer); err!=nil {
+        return "", err
+    }
+    return string(buffer), nil
+}
+
+//--------------------------------------------------------------------------------------------------
+func TestSyslogSinkTCPReconnect( t *testing.T) {
+    listener, err := net.Listen("tcp", "127.0.0.1:0")
+    if err!=nil {
+        t.Error(t.Name(),`Listen() failed:`,err)
+        return
+    }
+    defer listener.Close()
+    chConn := make(chan net.Conn, 4)
+    go func() {
+        for {
+            conn, err := listener.Accept()
+            if err!=nil {
+                return
+            }
+            chConn <- conn
+        }
+    }()
+
+    logger := NewLogger()
+    defer logger.Terminate()
+    _, err = logger.AddSyslogSinkWithProtocol("tcp", listener.Addr().String(), DaemonSyslogFacility, 
+                                              "test", RFC3164SyslogProtocol, InfoSeverity)
+    if err!=nil {
+        t.Error(t.Name(),`AddSyslogSinkWithProtocol() failed:`,err)
+        return
+    }
+    logger.Info("First\nmessage")
+    conn := <-chConn
+    conn.SetReadDeadline( time.Now().Add( 5*time.Second))
+    msg, err := readOctetCountedMessage( bufio.NewReader( conn))
+    if err!=nil {
+        t.Error(t.Name(),`Reading the first message failed:`,err)
+        return
+    }
+    if !strings.HasPrefix( msg, "<30>") || !strings.HasSuffix( msg, ": First\nmessage") {
+        t.Error(t.Name(),`Unexpected message:`,msg)
+    }
+    conn.Close()
+
+    // The first writes after the server closed the connection may still succeed.
+    deadline := time.Now().Add( 10*time.Second)
+    for time.Now().Before( deadline) {
+        logger.Info("Second message")
+        logger.Flush()
+        select {
+            case conn = <-chConn:
+                defer conn.Close()
+                conn.SetReadDeadline( time.Now().Add( 5*time.Second))
+                msg, err := readOctetCountedMessage( bufio.NewReader( conn))
+                if err!=nil {
+                    t.Error(t.Name(),`Reading after reconnecting failed:`,err)
+                } else if !strings.HasSuffix( msg, ": Second message") {
+                    t.Error(t.Name(),`Unexpected message:`,msg)
+                }
+                return
+            case <-time.After( 100*time.Millisecond):
+        }
+    }
+    t.Error(t.Name(),`The sink did not reconnect`)
+}
+
+//--------------------------------------------------------------------------------------------------
+func TestSyslogSinkLazyConnect( t *testing.T) {
+    // Finds a free port, nobody listens on it until the first message is dropped.
+    listener, err := net.Listen("tcp", "127.0.0.1:0")
+    if err!=nil {
+        t.Error(t.Name(),`Listen() failed:`,err)
+        return
+    }
+    address := listener.Addr().String()
+    listener.Close()
+
+    sink, err := newSyslogLogMessageSink("tcp", address, DaemonSyslogFacility, "test", 
+                                         RFC3164SyslogProtocol, InfoSeverity)
+    if err!=nil {
+        t.Error(t.Name(),`newSyslogLogMessageSink() failed with the server not reachable:`,err)
+        return
+    }
+    defer sink.Terminate()
+    var errs []error
+    sink.setErrorReporter( func( err error) { errs= append( errs, err) })
+    numDropped := 0
+    sink.setDropReporter( func() { numDropped++ })
+
+    timestamp := time.Date(2021, time.January, 6, 22, 29, 40, 0, time.UTC)
+    for _, text := range []string{"First message", "Second message"} {
+        msg := NewLogMessage( text, InfoSeverity, LogMessageType, timestamp, "main.go", 10, "main.main")
+        sink.OnLogMessage( msg)
+    }
+    if numDropped!=2 || len(errs)!=1 || !strings.Contains( errs[0].Error(), "messages dropped") {
+        t.Error(t.Name(),`Unexpected drops:`,numDropped,errs)
+    }
+
+    listener, err = net.Listen("tcp", address)
+    if err!=nil {
+        t.Error(t.Name(),`Listen() failed:`,err)
+        return
+    }
+    defer listener.Close()
+    // No need to wait for the next connection attempt.
+    sink.lastDialTime= time.Time{}
+    msg := NewLogMessage("Third message", InfoSeverity, LogMessageType, timestamp, "main.go", 10, "main.main")
+    sink.OnLogMessage( msg)
+    conn, err := listener.Accept()
+    if err!=nil {
+        t.Error(t.Name(),`Accept() failed:`,err)
+        return
+    }
+    defer conn.Close()
+    conn.SetReadDeadline( time.Now().Add( 5*time.Second))
+    text, err := readOctetCountedMessage( bufio.NewReader( conn))
+    if err!=nil || !strings.HasSuffix( text, ": Third message") {
+        t.Error(t.Name(),`Unexpected message:`,text,err)
+    }
+}
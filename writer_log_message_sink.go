@@ -0,0 +1,92 @@
+package dmlog
+
+import "errors"
+import "fmt"
+import "io"
+import "os"
+
+// Implementation of a log sink that writes messages to an io.Writer.
+type writerLogMessageSink struct {
+    BaseLogMessageSink
+    writer io.Writer
+}
+
+/* Adds a log message sink that writes messages to w, e.g. a bytes.Buffer, a pipe or a 
+   bufio.Writer, using the same formats as the console and the file sinks.
+   Flushing the sink calls the Flush() error and then the Sync() error methods of w, when they 
+   exist.  Terminating the sink flushes it and closes w if it is an io.Closer, except for the 
+   standard output and the standard error.
+   The writer is used by the logger goroutine only, it is not safe to access it elsewhere before
+   the sink is removed or the logger terminated.
+   In case error is nil, the returned message sink id can be used later to modify the severity 
+   threshold.*/
+func AddWriterSink( w io.Writer, threshold LogSeverity) (MessageSinkId, error) {
+    return DefaultLogger().AddWriterSink( w, threshold)
+}
+
+// Adds to the logger a log message sink that writes messages to w, see AddWriterSink().
+func (l *Logger) AddWriterSink( w io.Writer, threshold LogSeverity) (MessageSinkId, error) {
+    if w==nil {
+        return MessageSinkId(0), errors.New("invalid nil writer")
+    }
+    return l.addMessageSink( newWriterLogMessageSink( w, threshold))
+}
+
+//--------------------------------------------------------------------------------------------------
+func newWriterLogMessageSink( w io.Writer, threshold LogSeverity) *writerLogMessageSink {
+    return &writerLogMessageSink{ BaseLogMessageSink: NewBaseLogMessageSink( threshold, false),
+                                  writer: w, }
+}
+
+//--------------------------------------------------------------------------------------------------
+func (w *writerLogMessageSink) OnLogMessage( msg *LogMessage) {
+    if msg.severity.IsGreaterOrEqualThan( w.threshold) {
+        if _, err := io.WriteString( w.writer, w.FormatMessage( msg)); err!=nil {
+            w.ReportError( fmt.Errorf("failed while trying to write:%w",err))
+        }
+        if w.isFrequentFlush {
+            w.Flush()
+        }
+    }
+}
+
+//--------------------------------------------------------------------------------------------------
+// Same as FlushError(), the error is discarded.
+func (w *writerLogMessageSink) Flush() {
+    w.FlushError()
+}
+
+//--------------------------------------------------------------------------------------------------
+func (w *writerLogMessageSink) FlushError() error {
+    if flusher, ok := w.writer.(interface{ Flush() error }); ok {
+        if err := flusher.Flush(); err!=nil {
+            return err
+        }
+    }
+    if isStdStream( w.writer) {
+        // Sync() fails when the standard streams are a terminal or a pipe.
+        return nil
+    }
+    if syncer, ok := w.writer.(interface{ Sync() error }); ok {
+        return syncer.Sync()
+    }
+    return nil
+}
+
+//--------------------------------------------------------------------------------------------------
+func (w *writerLogMessageSink) Terminate() {
+    if err := w.FlushError(); err!=nil {
+        w.ReportError( fmt.Errorf("failed while trying to flush:%w",err))
+    }
+    if closer, ok := w.writer.(io.Closer); ok && !isStdStream( w.writer) {
+        if err := closer.Close(); err!=nil {
+            w.ReportError( fmt.Errorf("failed while trying to close:%w",err))
+        }
+    }
+}
+
+// Determines whether w is the standard output or the standard error.
+func isStdStream( w io.Writer) bool {
+    file, ok := w.(*os.File)
+    return ok && (file==os.Stdout || file==os.Stderr)
+}
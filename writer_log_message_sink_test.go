@@ -0,0 +1,74 @@
+package dmlog
+
+import "bufio"
+import "bytes"
+import "testing"
+
+// A writer recording whether it was flushed and closed.
+type closingBuffer struct {
+    bytes.Buffer
+    isFlushed bool
+    isClosed  bool
+}
+
+func (c *closingBuffer) Flush() error {
+    c.isFlushed= true
+    return nil
+}
+
+func (c *closingBuffer) Close() error {
+    c.isClosed= true
+    return nil
+}
+
+//--------------------------------------------------------------------------------------------------
+func TestWriterSink( t *testing.T) {
+    var buffer bytes.Buffer
+    logger := NewLogger()
+    sinkId, err := logger.AddWriterSink( &buffer, InfoSeverity)
+    if err!=nil {
+        t.Error(t.Name(),`AddWriterSink() failed:`,err)
+        return
+    }
+    logger.SetSinkOutputFormat( sinkId, LogMessageType, SeverityFmt, TextFmt)
+    logger.Debug("Debug message")
+    logger.Info("Info message")
+    logger.Print("Print message")
+    logger.Terminate()
+
+    expected := "[INF] Info message \nPrint message \n\n"
+    if buffer.String()!=expected {
+        t.Errorf("%s Unexpected output: got: %q expected: %q", t.Name(), buffer.String(), expected)
+    }
+}
+
+//--------------------------------------------------------------------------------------------------
+func TestWriterSinkFlushClose( t *testing.T) {
+    var output closingBuffer
+    writer := bufio.NewWriter( &output)
+    logger := NewLogger()
+    if _, err := logger.AddWriterSink( writer, InfoSeverity); err!=nil {
+        t.Error(t.Name(),`AddWriterSink() failed:`,err)
+        return
+    }
+    logger.Info("Info message")
+    if err := logger.Flush(); err!=nil {
+        t.Error(t.Name(),`Flush() failed:`,err)
+    }
+    if output.Len()==0 {
+        t.Error(t.Name(),`The bufio.Writer was not flushed`)
+    }
+
+    if _, err := logger.AddWriterSink( &output, InfoSeverity); err!=nil {
+        t.Error(t.Name(),`AddWriterSink() failed:`,err)
+        return
+    }
+    logger.Terminate()
+    if !output.isFlushed || !output.isClosed {
+        t.Error(t.Name(),`The writer was not flushed and closed:`,output.isFlushed,output.isClosed)
+    }
+
+    if _, err := AddWriterSink( nil, InfoSeverity); err==nil {
+        t.Error(t.Name(),`AddWriterSink() accepted a nil writer`)
+    }
+}